@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Actions recorded in the moderation log
+const (
+	actionApprove            = "approve"
+	actionAutoApprove        = "auto_approve"
+	actionDelete             = "delete"
+	actionDiscardImprovement = "discard_improvement"
+	actionImport             = "import"
+	actionRename             = "rename"
+	actionRestore            = "restore"
+	actionRevalidate         = "revalidate"
+)
+
+type ModerationLogEntry struct {
+	ID         string         `json:"id"`
+	Action     string         `json:"action"`
+	EntryID    string         `json:"entryId"`
+	Identifier string         `json:"identifier"`
+	Entry      map[string]any `json:"entry"`
+	Moderator  string         `json:"moderator"`
+	Reason     string         `json:"reason,omitempty"`
+	Created    string         `json:"created"`
+}
+
+// logModeration records what happened to an entry, snapshotting it as it was before the action.
+// Pass the transaction app so the log entry only exists if the action itself went through.
+func logModeration(app core.App, action string, entry *core.Record, moderator, reason string) error {
+	collection, err := app.FindCollectionByNameOrId("moderation_log")
+	if err != nil {
+		return err
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("action", action)
+	record.Set("entry_id", entry.Id)
+	record.Set("identifier", entry.GetString("identifier"))
+	record.Set("entry", entry.FieldsData())
+	record.Set("moderator", moderator)
+	record.Set("reason", reason)
+
+	return app.Save(record)
+}
+
+// moderatorIdentity works out who is acting, preferring a superuser session over the signed link recipient
+func moderatorIdentity(e *core.RequestEvent, linkRecipient string) string {
+	if e.HasSuperuserAuth() {
+		return e.Auth.Email()
+	}
+
+	if linkRecipient != "" {
+		return linkRecipient
+	}
+
+	return "signed link"
+}
+
+func (h *Handlers) getModerationLog(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+
+	limit := 100
+	if l := query.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+
+	filter := "id != ''"
+	params := dbx.Params{}
+	if identifier := query.Get("identifier"); identifier != "" {
+		filter += " && identifier = {:identifier}"
+		params["identifier"] = identifier
+	}
+	if entryID := query.Get("entry"); entryID != "" {
+		filter += " && entry_id = {:entry}"
+		params["entry"] = entryID
+	}
+	if action := query.Get("action"); action != "" {
+		filter += " && action = {:action}"
+		params["action"] = action
+	}
+
+	records, err := h.app.FindRecordsByFilter("moderation_log", filter, "-created", limit, 0, params)
+	if err != nil {
+		return e.InternalServerError("Failed to fetch moderation log", err)
+	}
+
+	entries := make([]ModerationLogEntry, len(records))
+	for i, record := range records {
+		var snapshot map[string]any
+		_ = record.UnmarshalJSONField("entry", &snapshot)
+
+		entries[i] = ModerationLogEntry{
+			ID:         record.Id,
+			Action:     record.GetString("action"),
+			EntryID:    record.GetString("entry_id"),
+			Identifier: record.GetString("identifier"),
+			Entry:      snapshot,
+			Moderator:  record.GetString("moderator"),
+			Reason:     record.GetString("reason"),
+			Created:    record.GetDateTime("created").Time().Format(time.RFC3339),
+		}
+	}
+
+	if query.Get("download") != "" {
+		e.Response.Header().Set("Content-Disposition", `attachment; filename="moderation-log.json"`)
+	}
+
+	return e.JSON(http.StatusOK, entries)
+}
+
+// moderationLogPage searches the moderation log and exports what it finds
+func (h *Handlers) moderationLogPage(e *core.RequestEvent) error {
+	return renderPage(e, http.StatusOK, "moderation_log", nil)
+}
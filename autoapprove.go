@@ -0,0 +1,85 @@
+package main
+
+import (
+	"log"
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// approvalCandidate is everything the auto-approval rules get to look at for a submission
+type approvalCandidate struct {
+	Score              int
+	NameClean          bool
+	Flags              []string
+	PreviouslyApproved bool
+}
+
+// findApprovalRule returns the first enabled rule (highest priority first) that the candidate
+// satisfies, or nil when the submission needs a human to look at it
+func (h *Handlers) findApprovalRule(c approvalCandidate) (*core.Record, error) {
+	rules, err := h.app.FindRecordsByFilter(
+		"approval_rules",
+		"enabled = true",
+		"-priority,created",
+		0,
+		0,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		if ruleMatches(rule, c) {
+			return rule, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ruleMatches checks every condition the rule has switched on, a rule with none set matches anything
+func ruleMatches(rule *core.Record, c approvalCandidate) bool {
+	if rule.GetBool("require_clean_name") && !c.NameClean {
+		return false
+	}
+
+	if rule.GetBool("require_score_match") && slices.Contains(c.Flags, flagScoreMismatch) {
+		return false
+	}
+
+	if rule.GetBool("require_no_flags") && len(c.Flags) > 0 {
+		return false
+	}
+
+	if rule.GetBool("require_previously_approved") && !c.PreviouslyApproved {
+		return false
+	}
+
+	if maxScore := rule.GetInt("max_score"); maxScore > 0 && c.Score > maxScore {
+		return false
+	}
+
+	return true
+}
+
+// autoApprove publishes the submission on the record and notes which rule let it through
+func autoApprove(record *core.Record, rule *core.Record) {
+	if hasPendingImprovement(record) {
+		applyPendingImprovement(record)
+	}
+
+	record.Set("approved", true)
+	record.Set("approval_rule", rule.Id)
+}
+
+// approvalRuleFor looks up a matching rule, falling back to manual moderation if the rules can't be read
+func (h *Handlers) approvalRuleFor(c approvalCandidate) *core.Record {
+	rule, err := h.findApprovalRule(c)
+	if err != nil {
+		log.Printf("failed to check approval rules: %v", err)
+		return nil
+	}
+
+	return rule
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// What happens to a banned player's submission
+const (
+	banModeReject = "reject" // submitScore refuses it outright
+	banModeShadow = "shadow" // accepted, but never moderated or shown publicly
+)
+
+// findActiveBan returns the ban that applies to this player, if any. Reject bans win over
+// shadow bans so a player matching both is told no rather than quietly ignored.
+func (h *Handlers) findActiveBan(identifier, name, rawName string) (*core.Record, error) {
+	bans, err := h.activeBans()
+	if err != nil {
+		return nil, err
+	}
+
+	return matchBan(bans, identifier, name, rawName), nil
+}
+
+// activeBans lists the bans that haven't expired, reject bans first
+func (h *Handlers) activeBans() ([]*core.Record, error) {
+	return h.app.FindRecordsByFilter(
+		"bans",
+		"expire_at = '' || expire_at > @now",
+		"mode,created",
+		0,
+		0,
+	)
+}
+
+func matchBan(bans []*core.Record, identifier, name, rawName string) *core.Record {
+	for _, ban := range bans {
+		if banMatches(ban, identifier, name, rawName) {
+			return ban
+		}
+	}
+
+	return nil
+}
+
+func banMatches(ban *core.Record, identifier, name, rawName string) bool {
+	value := ban.GetString("value")
+
+	switch ban.GetString("match_type") {
+	case "identifier":
+		return identifier == value
+	case "name":
+		return strings.EqualFold(name, value) || strings.EqualFold(strings.TrimSpace(rawName), value)
+	case "pattern":
+		re, err := regexp.Compile("(?i)" + value)
+		if err != nil {
+			log.Printf("skipping ban %s with invalid pattern %q: %v", ban.Id, value, err)
+			return false
+		}
+		return re.MatchString(name) || re.MatchString(rawName)
+	}
+
+	return false
+}
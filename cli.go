@@ -0,0 +1,402 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+)
+
+// registerLeaderboardCommands adds "leaderboard ..." to the app's command line, for moderating
+// on the server itself. Everything the commands do is logged like any other moderation.
+func registerLeaderboardCommands(app *pocketbase.PocketBase) {
+	var asJSON bool
+
+	root := &cobra.Command{
+		Use:   "leaderboard",
+		Short: "Moderate and inspect the leaderboard",
+	}
+	root.PersistentFlags().BoolVar(&asJSON, "json", false, "print JSON instead of a table")
+
+	output := func(cmd *cobra.Command, entries []AdminEntry, table func(io.Writer, []AdminEntry)) error {
+		if asJSON {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(entries)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		table(w, entries)
+		return w.Flush()
+	}
+
+	var pendingLimit int
+	pending := &cobra.Command{
+		Use:   "pending",
+		Short: "List entries waiting for moderation, oldest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			records, err := app.FindRecordsByFilter("leaderboard", statusFilters[statusPending], "created", pendingLimit, 0)
+			if err != nil {
+				return err
+			}
+
+			return output(cmd, adminEntriesFor(records), entryTable)
+		},
+	}
+	pending.Flags().IntVarP(&pendingLimit, "limit", "n", 100, "maximum number of entries")
+
+	var approveName, approveReason string
+	approve := &cobra.Command{
+		Use:   "approve <id>",
+		Short: "Approve an entry, or its pending improvement",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := NewHandlers(app)
+			record, err := h.findActiveEntry(args[0])
+			if err != nil {
+				return fmt.Errorf("no entry %s: %w", args[0], err)
+			}
+
+			if approveName != "" {
+				h.reloadWordLists()
+			}
+			if err := h.approveWithName(record, approveName, cliModerator(), approveReason); err != nil {
+				return err
+			}
+
+			return output(cmd, adminEntriesFor([]*core.Record{record}), entryTable)
+		},
+	}
+	approve.Flags().StringVar(&approveName, "name", "", "approve under a different name")
+	approve.Flags().StringVar(&approveReason, "reason", "", "reason kept in the moderation log")
+
+	var deleteReason string
+	deleteCmd := &cobra.Command{
+		Use:   "delete <id>",
+		Short: "Move an entry to the trash, or discard its pending improvement",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := NewHandlers(app)
+			record, err := h.findActiveEntry(args[0])
+			if err != nil {
+				return fmt.Errorf("no entry %s: %w", args[0], err)
+			}
+
+			err = app.RunInTransaction(func(txApp core.App) error {
+				_, err := deleteEntry(txApp, record, cliModerator(), deleteReason)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			return output(cmd, adminEntriesFor([]*core.Record{record}), entryTable)
+		},
+	}
+	deleteCmd.Flags().StringVar(&deleteReason, "reason", "", "reason kept in the moderation log")
+
+	var topLimit int
+	top := &cobra.Command{
+		Use:   "top",
+		Short: "Show the public leaderboard",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			records, err := app.FindRecordsByFilter(
+				"leaderboard",
+				"approved = true && shadowed = false && deleted_at = ''",
+				"-score",
+				topLimit,
+				0,
+			)
+			if err != nil {
+				return err
+			}
+
+			return output(cmd, adminEntriesFor(records), rankTable)
+		},
+	}
+	top.Flags().IntVarP(&topLimit, "limit", "n", 10, "number of places to show")
+
+	show := &cobra.Command{
+		Use:   "show <identifier>",
+		Short: "Show a player's entry, whatever state it's in",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			record, err := app.FindFirstRecordByFilter("leaderboard", "identifier = {:identifier}", dbx.Params{"identifier": args[0]})
+			if err != nil {
+				return fmt.Errorf("no entry for %s: %w", args[0], err)
+			}
+
+			return output(cmd, adminEntriesFor([]*core.Record{record}), detailTable)
+		},
+	}
+
+	var signModerator string
+	signURL := &cobra.Command{
+		Use:       "sign-url <approve|delete|restore> <id>",
+		Short:     "Print a signed moderation link for an entry",
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: []string{"approve", "delete", "restore"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			action, id := args[0], args[1]
+			if action != "approve" && action != "delete" && action != "restore" {
+				return fmt.Errorf("unknown action %q, use approve, delete or restore", action)
+			}
+
+			h := NewHandlers(app)
+			if h.getSigningKey() == "" {
+				return errors.New("ADMIN_SIGNING_KEY is not set")
+			}
+			if _, err := app.FindRecordById("leaderboard", id); err != nil {
+				return fmt.Errorf("no entry %s: %w", id, err)
+			}
+
+			link := signedModerationURL(app, action, id, signModerator, h)
+			if asJSON {
+				return json.NewEncoder(cmd.OutOrStdout()).Encode(map[string]string{"url": link})
+			}
+
+			_, err := fmt.Fprintln(cmd.OutOrStdout(), link)
+			return err
+		},
+	}
+	signURL.Flags().StringVar(&signModerator, "moderator", "", "email address the link is issued to, recorded as the moderator")
+
+	var exportFormat, exportOutput string
+	var exportOpts exportOptions
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Export entries as CSV or NDJSON, without player identifiers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			if exportOutput != "" && exportOutput != "-" {
+				if exportFormat == "" {
+					exportFormat = formatFromName(filepath.Ext(exportOutput))
+				}
+
+				file, err := os.Create(exportOutput)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				out = file
+			}
+			if exportFormat == "" {
+				exportFormat = formatNDJSON
+			}
+
+			count, err := exportEntries(app, out, exportFormat, exportOpts)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "exported %d entries\n", count)
+			return nil
+		},
+	}
+	export.Flags().StringVar(&exportFormat, "format", "", "csv or ndjson (default from the output file name, else ndjson)")
+	export.Flags().StringVarP(&exportOutput, "output", "o", "", "file to write, stdout if not given")
+	export.Flags().StringVar(&exportOpts.Status, "status", defaultExportStatus, "pending, approved, shadowed, deleted or all")
+	export.Flags().StringVar(&exportOpts.Since, "since", "", "only entries created on or after this date")
+	export.Flags().StringVar(&exportOpts.Until, "until", "", "only entries created before this date")
+
+	var importFormat, importConflict string
+	var importDryRun bool
+	importCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import entries from a CSV or NDJSON export, \"-\" for stdin",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if importFormat == "" {
+				importFormat = formatFromName(filepath.Ext(args[0]))
+			}
+
+			file, err := openImportFile(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			h := NewHandlers(app)
+			h.reloadWordLists()
+
+			report, err := h.importEntries(file, importFormat, importConflict, importDryRun)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				importReportTable(cmd.OutOrStdout(), report)
+			}
+
+			if len(report.Errors) > 0 && !importDryRun {
+				return errors.New("import failed, nothing was written")
+			}
+
+			return nil
+		},
+	}
+	importCmd.Flags().StringVar(&importFormat, "format", "", "csv or ndjson (default from the file name)")
+	importCmd.Flags().StringVar(&importConflict, "conflict", conflictSkip, "for entries that already exist: skip, overwrite or keep-best")
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "report what would happen without writing anything")
+
+	seedOpts := seedOptions{}
+	seed := &cobra.Command{
+		Use:   "seed",
+		Short: "Replace any seeded test players with a fresh set of fake ones",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return seedLeaderboard(app, seedOpts, cmd.ErrOrStderr())
+		},
+	}
+	seed.Flags().IntVar(&seedOpts.Players, "players", 1000, "number of players to create")
+	seed.Flags().Float64Var(&seedOpts.ApprovedRatio, "approved-ratio", 0.9, "share of players that are approved, the rest wait for moderation")
+	seed.Flags().Uint64Var(&seedOpts.Seed, "seed", 1, "random seed, the same seed gives the same players")
+
+	var revalidateDryRun bool
+	revalidate := &cobra.Command{
+		Use:   "revalidate",
+		Short: "Check every entry against the current rules, un-approving or flagging the ones that fail",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := NewHandlers(app)
+			h.reloadWordLists()
+
+			report, err := h.revalidateEntries(revalidateDryRun)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(report)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			revalidationReportTable(w, report)
+			return w.Flush()
+		},
+	}
+	revalidate.Flags().BoolVar(&revalidateDryRun, "dry-run", false, "report what would change without changing anything")
+
+	root.AddCommand(pending, approve, deleteCmd, top, show, signURL, export, importCmd, seed, revalidate)
+	for _, cmd := range root.Commands() {
+		// A missing entry or a failed save isn't a usage mistake
+		cmd.SilenceUsage = true
+	}
+	app.RootCmd.AddCommand(root)
+}
+
+// cliModerator is who the moderation log credits for command line actions
+func cliModerator() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return "cli:" + u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return "cli:" + name
+	}
+
+	return "cli"
+}
+
+func entryTable(w io.Writer, entries []AdminEntry) {
+	fmt.Fprintln(w, "ID\tNAME\tSCORE\tLEVELS\tSTATUS\tFLAGS\tCREATED")
+	for _, entry := range entries {
+		name, score, levels, flags := entry.Name, entry.Score, entry.LevelsCompleted, entry.Flags
+		if entry.Pending != nil {
+			name, score, levels, flags = entry.Pending.Name, entry.Pending.Score, entry.Pending.LevelsCompleted, entry.Pending.Flags
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d/20\t%s\t%s\t%s\n",
+			entry.ID, name, score, levels, entry.Status, strings.Join(flags, ","), entry.Created)
+	}
+}
+
+func rankTable(w io.Writer, entries []AdminEntry) {
+	fmt.Fprintln(w, "RANK\tID\tNAME\tSCORE\tLEVELS")
+	for i, entry := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d/20\n", i+1, entry.ID, entry.Name, entry.Score, entry.LevelsCompleted)
+	}
+}
+
+func detailTable(w io.Writer, entries []AdminEntry) {
+	for _, entry := range entries {
+		fmt.Fprintf(w, "ID\t%s\n", entry.ID)
+		fmt.Fprintf(w, "Name\t%s\n", entry.Name)
+		fmt.Fprintf(w, "Identifier\t%s\n", entry.Identifier)
+		fmt.Fprintf(w, "Score\t%d\n", entry.Score)
+		fmt.Fprintf(w, "Levels\t%d/20\n", entry.LevelsCompleted)
+		fmt.Fprintf(w, "Completion time\t%ds\n", entry.CompletionTime/1000)
+		fmt.Fprintf(w, "Status\t%s\n", entry.Status)
+		fmt.Fprintf(w, "Shadowed\t%t\n", entry.Shadowed)
+		fmt.Fprintf(w, "Flags\t%s\n", strings.Join(entry.Flags, ", "))
+		fmt.Fprintf(w, "Created\t%s\n", entry.Created)
+		if entry.RenamedFrom != "" {
+			fmt.Fprintf(w, "Renamed from\t%s\n", entry.RenamedFrom)
+		}
+		if entry.DeletedAt != "" {
+			fmt.Fprintf(w, "Deleted\t%s\n", entry.DeletedAt)
+		}
+		if entry.Pending != nil {
+			fmt.Fprintf(w, "Pending\t%s, %d points, %d/20 levels, submitted %s\n",
+				entry.Pending.Name, entry.Pending.Score, entry.Pending.LevelsCompleted, entry.Pending.Submitted)
+			fmt.Fprintf(w, "Pending flags\t%s\n", strings.Join(entry.Pending.Flags, ", "))
+		}
+	}
+}
+
+func importReportTable(w io.Writer, report *ImportReport) {
+	switch {
+	case report.DryRun:
+		fmt.Fprintln(w, "Dry run, nothing was written.")
+	case !report.Applied:
+		fmt.Fprintln(w, "Import rolled back, nothing was written.")
+	}
+	fmt.Fprintf(w, "Created: %d\nUpdated: %d\nSkipped: %d\nErrors:  %d\n", report.Created, report.Updated, report.Skipped, len(report.Errors))
+	if report.Flagged > 0 {
+		fmt.Fprintf(w, "Flagged: %d (%d unapproved)\n", report.Flagged, report.Unapproved)
+	}
+
+	for _, e := range report.Errors {
+		if e.ID != "" {
+			fmt.Fprintf(w, "  line %d (%s): %s\n", e.Line, e.ID, e.Message)
+		} else {
+			fmt.Fprintf(w, "  line %d: %s\n", e.Line, e.Message)
+		}
+	}
+}
+
+func revalidationReportTable(w io.Writer, report *RevalidationReport) {
+	if len(report.Entries) > 0 {
+		fmt.Fprintln(w, "ID\tNAME\tSCORE\tACTION\tPROBLEMS")
+		for _, entry := range report.Entries {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", entry.ID, entry.Name, entry.Score, entry.Action, strings.Join(entry.Problems, ","))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if report.DryRun {
+		fmt.Fprintln(w, "Dry run, nothing was changed.")
+	}
+	fmt.Fprintf(w, "Checked %d entries: %d un-approved, %d shadowed, %d flagged\n",
+		report.Checked, report.Unapproved, report.Shadowed, report.Flagged)
+}
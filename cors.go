@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// CORS is configured per route group with CORS_<GROUP>_<SETTING>, falling back to CORS_<SETTING>
+// for every group and then to the defaults below. Settings are ALLOWED_ORIGINS, ALLOWED_METHODS
+// and ALLOWED_HEADERS (comma separated), ALLOW_CREDENTIALS (true/false) and MAX_AGE (seconds).
+// Origins can use wildcards, "https://*.example.com" allows every subdomain and "*" anything.
+//
+// So CORS_READ_ALLOWED_ORIGINS=* opens up the public leaderboard for embedding anywhere while
+// CORS_SUBMIT_ALLOWED_ORIGINS=https://game.example.com keeps score submission to the game itself.
+//
+// This replaces PocketBase's own CORS middleware. Without ALLOWED_ORIGINS its --origins flag
+// still applies, "*" unless given, so a deployment that sets neither behaves as it always has.
+
+// Route groups with their own policy
+const (
+	corsGroupRead    = "read"    // the public leaderboard endpoints
+	corsGroupSubmit  = "submit"  // score submission
+	corsGroupDefault = "default" // everything else, including the admin API
+)
+
+var defaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+
+var defaultCORSHeaders = []string{"Content-Type", "Authorization"}
+
+const defaultCORSMaxAge = 86400
+
+// corsGroup works out which policy a request falls under from its path
+func corsGroup(r *http.Request) string {
+	switch path := r.URL.Path; {
+	case path == "/api/leaderboard/submit":
+		return corsGroupSubmit
+	case path == "/api/leaderboard" || strings.HasPrefix(path, "/api/leaderboard/"):
+		return corsGroupRead
+	default:
+		return corsGroupDefault
+	}
+}
+
+// corsSetting looks up CORS_<GROUP>_<name>, then CORS_<name>
+func corsSetting(group, name string) string {
+	if group != corsGroupDefault {
+		if value := os.Getenv("CORS_" + strings.ToUpper(group) + "_" + name); value != "" {
+			return value
+		}
+	}
+
+	return os.Getenv("CORS_" + name)
+}
+
+func corsList(group, name string, fallback []string) []string {
+	value := corsSetting(group, name)
+	if value == "" {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+func corsConfig(group string, fallbackOrigins []string) apis.CORSConfig {
+	config := apis.CORSConfig{
+		AllowOrigins: corsList(group, "ALLOWED_ORIGINS", fallbackOrigins),
+		AllowMethods: corsList(group, "ALLOWED_METHODS", defaultCORSMethods),
+		AllowHeaders: corsList(group, "ALLOWED_HEADERS", defaultCORSHeaders),
+		MaxAge:       defaultCORSMaxAge,
+	}
+
+	if credentials, err := strconv.ParseBool(corsSetting(group, "ALLOW_CREDENTIALS")); err == nil {
+		config.AllowCredentials = credentials
+	}
+	if maxAge, err := strconv.Atoi(corsSetting(group, "MAX_AGE")); err == nil {
+		config.MaxAge = maxAge
+	}
+
+	return config
+}
+
+// serveOrigins is the serve command's --origins flag, what PocketBase's middleware would have allowed
+func serveOrigins(app *pocketbase.PocketBase) []string {
+	if serve, _, err := app.RootCmd.Find([]string{"serve"}); err == nil {
+		if origins, err := serve.Flags().GetStringSlice("origins"); err == nil && len(origins) > 0 {
+			return origins
+		}
+	}
+
+	return []string{"*"}
+}
+
+// corsMiddleware picks the policy for each request's route group. It's bound in place of the
+// default PocketBase CORS middleware, under the same id and priority, so it runs just as early
+// and answers preflight requests before anything else sees them.
+func corsMiddleware(fallbackOrigins []string) *hook.Handler[*core.RequestEvent] {
+	policies := map[string]*hook.Handler[*core.RequestEvent]{}
+	for _, group := range []string{corsGroupRead, corsGroupSubmit, corsGroupDefault} {
+		policies[group] = apis.CORS(corsConfig(group, fallbackOrigins))
+	}
+
+	return &hook.Handler[*core.RequestEvent]{
+		Id:       apis.DefaultCorsMiddlewareId,
+		Priority: apis.DefaultCorsMiddlewarePriority,
+		Func: func(e *core.RequestEvent) error {
+			return policies[corsGroup(e.Request)].Func(e)
+		},
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+)
+
+// Where outgoing mail goes, set with MAILER_MODE. Spool is the default under "go run"
+// since there's rarely an SMTP server on a laptop.
+const (
+	mailerModeSMTP  = "smtp"
+	mailerModeSpool = "spool"
+)
+
+func mailerMode(isGoRun bool) string {
+	switch mode := os.Getenv("MAILER_MODE"); mode {
+	case mailerModeSMTP, mailerModeSpool:
+		return mode
+	}
+
+	if isGoRun {
+		return mailerModeSpool
+	}
+
+	return mailerModeSMTP
+}
+
+// mailSpoolDir is MAIL_SPOOL_DIR, or mail_spool inside the PocketBase data dir
+func mailSpoolDir(app core.App) string {
+	if dir := os.Getenv("MAIL_SPOOL_DIR"); dir != "" {
+		return dir
+	}
+
+	return filepath.Join(app.DataDir(), "mail_spool")
+}
+
+// registerMailSpool swaps sending for writing .eml files and serves them at /_dev/mail.
+// Only call this in spool mode, the viewer shows working signed moderation links.
+func registerMailSpool(app *pocketbase.PocketBase) {
+	app.OnMailerSend().BindFunc(func(e *core.MailerEvent) error {
+		dir := mailSpoolDir(e.App)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+
+		eml, err := buildEML(e.Message)
+		if err != nil {
+			return err
+		}
+
+		suffix := make([]byte, 4)
+		rand.Read(suffix)
+		name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000"), hex.EncodeToString(suffix))
+
+		// Deliberately not calling e.Next(), the message never leaves the machine
+		return os.WriteFile(filepath.Join(dir, name), eml, 0o644)
+	})
+
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		// The spool holds real moderation links, so only whoever runs the server gets to read it
+		mail := se.Router.Group("/_dev/mail")
+		mail.BindFunc(func(e *core.RequestEvent) error {
+			if !isLoopbackRequest(e.Request) {
+				return e.ForbiddenError("The mail viewer is only available from loopback", nil)
+			}
+			return e.Next()
+		})
+		mail.GET("", devMailIndex)
+		mail.GET("/{name}", devMailMessage)
+
+		return se.Next()
+	})
+}
+
+// buildEML writes the message as a multipart/alternative RFC 5322 message
+func buildEML(message *mailer.Message) ([]byte, error) {
+	var buf bytes.Buffer
+
+	to := make([]string, len(message.To))
+	for i, address := range message.To {
+		to[i] = address.String()
+	}
+
+	body := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", message.From.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	for key, value := range message.Headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", body.Boundary())
+
+	parts := []struct{ contentType, content string }{
+		{"text/plain", message.Text},
+		{"text/html", message.HTML},
+	}
+	for _, part := range parts {
+		if part.content == "" {
+			continue
+		}
+
+		w, err := body.CreatePart(map[string][]string{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type spooledMessage struct {
+	Name    string
+	From    string
+	To      string
+	Subject string
+	Date    string
+	Text    string
+	HTML    string
+}
+
+// readSpooledMessage parses one .eml file back into its headers and text/html parts
+func readSpooledMessage(path string) (*spooledMessage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	msg, err := mail.ReadMessage(file)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := new(mime.WordDecoder)
+	header := func(key string) string {
+		value, err := decoder.DecodeHeader(msg.Header.Get(key))
+		if err != nil {
+			return msg.Header.Get(key)
+		}
+		return value
+	}
+
+	result := &spooledMessage{
+		Name:    filepath.Base(path),
+		From:    header("From"),
+		To:      header("To"),
+		Subject: header("Subject"),
+		Date:    msg.Header.Get("Date"),
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		content, err := io.ReadAll(msg.Body)
+		if err != nil {
+			return nil, err
+		}
+		result.Text = string(content)
+		return result, nil
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// NextPart already undoes quoted-printable encoding
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "text/plain":
+			result.Text = string(content)
+		case "text/html":
+			result.HTML = string(content)
+		}
+	}
+
+	return result, nil
+}
+
+func devMailIndex(e *core.RequestEvent) error {
+	dir := mailSpoolDir(e.App)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return e.InternalServerError("Failed to read mail spool", err)
+	}
+
+	// Names start with a timestamp, so reverse order is newest first
+	slices.Reverse(entries)
+
+	// A message that can't be read is listed with its error instead
+	type listedMessage struct {
+		*spooledMessage
+		File string
+		Err  error
+	}
+
+	var messages []listedMessage
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".eml" {
+			continue
+		}
+
+		msg, err := readSpooledMessage(filepath.Join(dir, entry.Name()))
+		messages = append(messages, listedMessage{spooledMessage: msg, File: entry.Name(), Err: err})
+	}
+
+	return renderPage(e, http.StatusOK, "dev_mail", map[string]any{
+		"Dir":      dir,
+		"Messages": messages,
+	})
+}
+
+var spooledLinkPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// devMailMessage shows a spooled message, the HTML part in a sandboxed frame and the text part
+// with its links made clickable. ?raw=1 downloads the .eml file.
+func devMailMessage(e *core.RequestEvent) error {
+	name := filepath.Base(e.Request.PathValue("name"))
+	path := filepath.Join(mailSpoolDir(e.App), name)
+
+	if e.Request.URL.Query().Get("raw") != "" {
+		e.Response.Header().Set("Content-Type", "message/rfc822")
+		e.Response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		return e.FileFS(os.DirFS(filepath.Dir(path)), name)
+	}
+
+	msg, err := readSpooledMessage(path)
+	if err != nil {
+		return e.NotFoundError("Message not found", err)
+	}
+
+	// The HTML part inherits this page's CSP through srcdoc
+	allowInlineStyleAttributes(e)
+	return renderPage(e, http.StatusOK, "dev_mail_message", struct {
+		*spooledMessage
+		TextParts []textPart
+	}{msg, linkTextParts(msg.Text)})
+}
+
+// textPart is a run of plain text or a link found in it
+type textPart struct {
+	Text string
+	Link string
+}
+
+// linkTextParts splits a text part around the links in it so the page can make them clickable
+func linkTextParts(text string) []textPart {
+	var parts []textPart
+	last := 0
+	for _, match := range spooledLinkPattern.FindAllStringIndex(text, -1) {
+		parts = append(parts, textPart{Text: text[last:match[0]]}, textPart{Link: text[match[0]:match[1]]})
+		last = match[1]
+	}
+
+	return append(parts, textPart{Text: text[last:]})
+}
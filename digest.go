@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// How moderation notifications go out. MODERATION_DELIVERY sets the default,
+// superusers can override it in moderator_preferences.
+const (
+	deliveryImmediate = "immediate" // one email per submission
+	deliveryDigest    = "digest"    // batched on MODERATION_DIGEST_SCHEDULE
+	deliveryBoth      = "both"
+)
+
+const defaultDigestSchedule = "0 * * * *"
+
+func moderationDeliveryMode() string {
+	switch mode := os.Getenv("MODERATION_DELIVERY"); mode {
+	case deliveryDigest, deliveryBoth:
+		return mode
+	default:
+		return deliveryImmediate
+	}
+}
+
+func digestSchedule() string {
+	if schedule := os.Getenv("MODERATION_DIGEST_SCHEDULE"); schedule != "" {
+		return schedule
+	}
+
+	return defaultDigestSchedule
+}
+
+// RegisterCronJobs schedules the background jobs. The digest always runs since any superuser
+// may have opted into it, it does nothing when nobody wants one.
+func (h *Handlers) RegisterCronJobs() {
+	h.app.Cron().MustAdd("notificationOutbox", "* * * * *", func() {
+		if err := h.processOutbox(); err != nil {
+			log.Printf("failed to process outbox: %v", err)
+		}
+	})
+
+	h.app.Cron().MustAdd("moderationDigest", digestSchedule(), func() {
+		if err := h.sendModerationDigest(); err != nil {
+			log.Printf("failed to send moderation digest: %v", err)
+		}
+	})
+
+	h.app.Cron().MustAdd("purgeTrash", "30 3 * * *", func() {
+		if err := h.purgeTrash(); err != nil {
+			log.Printf("failed to purge trash: %v", err)
+		}
+	})
+
+	if schedule := revalidationSchedule(); schedule != "off" {
+		h.app.Cron().MustAdd("revalidateEntries", schedule, h.runRevalidation)
+	}
+}
+
+// sendModerationDigest emails every superuser who wants digests what has come up since their last
+// one. Each recipient's digests are tracked on their own, so someone in their quiet hours or whose
+// email failed gets everything they missed in the first run after.
+func (h *Handlers) sendModerationDigest() error {
+	recipients, err := h.emailService.moderationRecipients()
+	if err != nil {
+		return err
+	}
+
+	issued := time.Now().Unix()
+
+	var errs []error
+	for _, recipient := range recipients {
+		if !recipient.wantsDigest() || recipient.inQuietHours() {
+			continue
+		}
+
+		if err := h.sendDigestTo(recipient, issued); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// sendDigestTo sends one recipient the entries waiting for moderation that changed after their
+// last digest, up to issued
+func (h *Handlers) sendDigestTo(recipient moderationRecipient, issued int64) error {
+	since, err := h.lastDigest(recipient.Email)
+	if err != nil {
+		return err
+	}
+
+	until, err := types.ParseDateTime(time.Unix(issued, 0))
+	if err != nil {
+		return err
+	}
+
+	records, err := h.app.FindRecordsByFilter(
+		"leaderboard",
+		"shadowed = false && deleted_at = '' && (approved = false || pending_submitted != '') && updated > {:since} && updated <= {:until}",
+		"created",
+		0,
+		0,
+		dbx.Params{"since": since.String(), "until": until.String()},
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := h.emailService.SendModerationDigest(recipient, records, issued, h); err != nil {
+		return err
+	}
+
+	collection, err := h.app.FindCollectionByNameOrId("moderation_digests")
+	if err != nil {
+		return err
+	}
+
+	digest := core.NewRecord(collection)
+	digest.Set("recipient", recipient.Email)
+	digest.Set("issued", until)
+	digest.Set("entries", len(records))
+
+	return h.app.Save(digest)
+}
+
+// lastDigest is when the recipient's last digest was issued, zero if they haven't had one
+func (h *Handlers) lastDigest(email string) (types.DateTime, error) {
+	records, err := h.app.FindRecordsByFilter(
+		"moderation_digests",
+		"recipient = {:recipient}",
+		"-issued",
+		1,
+		0,
+		dbx.Params{"recipient": email},
+	)
+	if err != nil || len(records) == 0 {
+		return types.DateTime{}, err
+	}
+
+	return records[0].GetDateTime("issued"), nil
+}
+
+// approveAllBatch is the value signed for an "approve all listed" link
+func approveAllBatch(ids []string, issued int64) string {
+	return fmt.Sprintf("%s@%d", strings.Join(ids, ","), issued)
+}
+
+func (h *Handlers) signedApproveAll(e *core.RequestEvent) error {
+	ids := strings.Split(e.Request.PathValue("ids"), ",")
+	signature := e.Request.PathValue("signature")
+	recipient := e.Request.URL.Query().Get("moderator")
+
+	issued, err := strconv.ParseInt(e.Request.PathValue("issued"), 10, 64)
+	if err != nil {
+		return e.BadRequestError("Invalid batch", err)
+	}
+
+	if !h.verifySignature("approve-all", approveAllBatch(ids, issued), recipient, signature) {
+		metrics.signatureFailures.inc("approve-all")
+		return e.BadRequestError("Invalid signature", nil)
+	}
+
+	records, err := h.app.FindRecordsByIds("leaderboard", ids)
+	if err != nil {
+		return e.InternalServerError("Failed to fetch scores", err)
+	}
+
+	// Skip anything already handled or resubmitted since the digest went out
+	var waiting []*core.Record
+	for _, record := range records {
+		stillPending := !isDeleted(record) && (!record.GetBool("approved") || hasPendingImprovement(record))
+		if stillPending && !record.GetDateTime("updated").Time().After(time.Unix(issued, 0)) {
+			waiting = append(waiting, record)
+		}
+	}
+
+	batch := "approve-all:" + approveAllBatch(ids, issued)
+	if e.Request.Method == "POST" {
+		if !h.verifyCSRF(e, batch) {
+			return csrfError(e)
+		}
+		return h.doApproveAll(e, waiting, moderatorIdentity(e, recipient))
+	}
+
+	data := approveAllPageData{Listed: len(ids), CSRF: h.csrfToken(e, batch)}
+	for _, record := range waiting {
+		data.Entries = append(data.Entries, submissionDetailsFor(record))
+	}
+
+	return renderPage(e, http.StatusOK, "approve_all", data)
+}
+
+func (h *Handlers) doApproveAll(e *core.RequestEvent, records []*core.Record, moderator string) error {
+	reason := e.Request.FormValue("reason")
+
+	err := h.app.RunInTransaction(func(txApp core.App) error {
+		for _, record := range records {
+			if err := approveEntry(txApp, record, moderator, reason); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return e.InternalServerError("Failed to approve scores", err)
+	}
+
+	return renderPage(e, http.StatusOK, "approved", approvedPageData{Count: len(records)})
+}
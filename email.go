@@ -2,8 +2,9 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"net/mail"
+	"net/url"
+	"strings"
 
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
@@ -17,7 +18,7 @@ type EmailService struct {
 }
 
 type SignatureGenerator interface {
-	generateSignature(action, id string) string
+	generateSignature(action, id, moderator string) string
 }
 
 func NewEmailService(app *pocketbase.PocketBase) *EmailService {
@@ -26,70 +27,102 @@ func NewEmailService(app *pocketbase.PocketBase) *EmailService {
 	}
 }
 
-func (e *EmailService) SendModerationEmail(id, name string, score int, levelsCompleted int, _ string, isNew bool, signer SignatureGenerator) error {
-	// Get admin email from app settings
-	var admins []*core.Record
-
-	err := e.app.RecordQuery(core.CollectionNameSuperusers).All(&admins)
-
+// SendModerationEmail emails one superuser about a submission, with links signed for them. It
+// returns errRecipientGone when they've since stopped wanting immediate emails, and holds the
+// email back with a deferredError while they're in their quiet hours.
+func (e *EmailService) SendModerationEmail(notice ModerationNotice, email string, signer SignatureGenerator) error {
+	recipient, err := e.moderationRecipient(email)
 	if err != nil {
-		log.Fatalf("failed to fetch admins: %v", err)
+		return err
+	}
+	if recipient == nil || !recipient.wantsImmediate() {
+		return errRecipientGone
+	}
+	if recipient.inQuietHours() {
+		return &deferredError{until: recipient.quietHoursEnd()}
 	}
 
-	var email = ""
-	for _, rec := range admins {
-		email = rec.GetString("email")
-		log.Printf("admin email: %s", email)
+	subject, text, html, err := e.renderModerationEmail(notice, recipient.Email, signer)
+	if err != nil {
+		return err
 	}
 
-	if len(email) == 0 {
-		return fmt.Errorf("no admin emails configured")
+	if err := e.send(recipient.Email, subject, text, html, replyHeaders(e.app, notice.ID, recipient.Email, signer)); err != nil {
+		return fmt.Errorf("failed to email %s: %w", recipient.Email, err)
 	}
 
+	return nil
+}
+
+// renderModerationEmail builds the moderation email for one recipient, with links signed for them
+func (e *EmailService) renderModerationEmail(notice ModerationNotice, email string, signer SignatureGenerator) (subject, text, html string, err error) {
 	action := "updated"
-	if isNew {
+	if notice.IsNew {
 		action = "submitted"
 	}
 
-	// Generate signed URLs
-	approveSignature := signer.generateSignature("approve", id)
-	deleteSignature := signer.generateSignature("delete", id)
-
-	baseURL := e.app.Settings().Meta.AppURL
-	if baseURL == "" {
-		baseURL = "http://localhost:8080" // Default for development
+	data := moderationMailData{
+		ModerationNotice: notice,
+		Subject:          fmt.Sprintf("Cookie Banner Clicker - New Score %s", cases.Title(language.English).String(action)),
+		Action:           action,
+		// Generate signed URLs
+		ApproveURL: signedModerationURL(e.app, "approve", notice.ID, email, signer),
+		DeleteURL:  signedModerationURL(e.app, "delete", notice.ID, email, signer),
 	}
 
-	approveURL := fmt.Sprintf("%s/admin/approve/%s/%s", baseURL, id, approveSignature)
-	deleteURL := fmt.Sprintf("%s/admin/delete/%s/%s", baseURL, id, deleteSignature)
-
-	subject := fmt.Sprintf("Cookie Banner Clicker - New Score %s", cases.Title(language.English).String(action))
+	text, html, err = renderMail("moderation", data)
+	return data.Subject, text, html, err
+}
 
-	body := fmt.Sprintf(`A new leaderboard score has been %s and requires moderation:
+// SendModerationDigest emails one superuser every listed entry, each with its own signed links plus
+// a single link approving everything in the batch. The batch is identified by issued, anything that
+// changed after it drops out of "approve all".
+func (e *EmailService) SendModerationDigest(recipient moderationRecipient, entries []*core.Record, issued int64, signer SignatureGenerator) error {
+	ids := make([]string, len(entries))
+	for i, record := range entries {
+		ids[i] = record.Id
+	}
+	batch := approveAllBatch(ids, issued)
 
-Player Name: %s
-Score: %d
-Levels Completed: %d
-Action: %s
+	data := digestMailData{
+		Subject: fmt.Sprintf("Cookie Banner Clicker - %d Scores Awaiting Moderation", len(entries)),
+		ApproveAllURL: fmt.Sprintf("%s/admin/approve-all/%s/%d/%s?moderator=%s", appBaseURL(e.app), strings.Join(ids, ","), issued,
+			signer.generateSignature("approve-all", batch, recipient.Email), url.QueryEscape(recipient.Email)),
+	}
+	for _, record := range entries {
+		data.Entries = append(data.Entries, digestMailEntry{
+			ModerationNotice: noticeFromRecord(record),
+			ApproveURL:       signedModerationURL(e.app, "approve", record.Id, recipient.Email, signer),
+			DeleteURL:        signedModerationURL(e.app, "delete", record.Id, recipient.Email, signer),
+		})
+	}
 
-Quick Actions:
-• ✅ Approve: %s
-• 🗑️ Delete: %s
+	text, html, err := renderMail("digest", data)
+	if err != nil {
+		return err
+	}
 
-These links are secure and can only be used by authorized administrators.
+	if err := e.send(recipient.Email, data.Subject, text, html, nil); err != nil {
+		metrics.notifications.inc("digest", notificationFailure)
+		return fmt.Errorf("failed to email %s: %w", recipient.Email, err)
+	}
+	metrics.notifications.inc("digest", notificationSuccess)
 
-Best regards,
-Cookie Banner Clicker Moderation System`, action, name, score, levelsCompleted, action, approveURL, deleteURL)
+	return nil
+}
 
+func (e *EmailService) send(to, subject, text, html string, headers map[string]string) error {
 	// Use PocketBase's built-in mailer
 	message := &mailer.Message{
 		From: mail.Address{
 			Address: e.app.Settings().Meta.SenderAddress,
 			Name:    e.app.Settings().Meta.SenderName,
 		},
-		To:      []mail.Address{{Address: email}},
+		To:      []mail.Address{{Address: to}},
 		Subject: subject,
-		Text:    body,
+		Text:    text,
+		HTML:    html,
+		Headers: headers,
 	}
 
 	return e.app.NewMailClient().Send(message)
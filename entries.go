@@ -0,0 +1,213 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Entry states as the moderation API reports and filters them
+const (
+	statusPending  = "pending" // new and unapproved, or approved with an improvement waiting
+	statusApproved = "approved"
+	statusShadowed = "shadowed"
+	statusDeleted  = "deleted"
+)
+
+var statusFilters = map[string]string{
+	"":             "deleted_at = ''",
+	statusPending:  "deleted_at = '' && (approved = false || pending_submitted != '')",
+	statusApproved: "deleted_at = '' && approved = true",
+	statusShadowed: "deleted_at = '' && shadowed = true",
+	statusDeleted:  "deleted_at != ''",
+}
+
+type PendingImprovement struct {
+	Name            string   `json:"name"`
+	Score           int      `json:"score"`
+	LevelsCompleted int      `json:"levelsCompleted"`
+	CompletionTime  int      `json:"completionTime"`
+	Flags           []string `json:"flags"`
+	RenamedFrom     string   `json:"renamedFrom,omitempty"`
+	Submitted       string   `json:"submitted"`
+}
+
+// AdminEntry is a leaderboard entry with everything a moderator gets to see
+type AdminEntry struct {
+	ID              string              `json:"id"`
+	Name            string              `json:"name"`
+	Identifier      string              `json:"identifier"`
+	Score           int                 `json:"score"`
+	LevelsCompleted int                 `json:"levelsCompleted"`
+	CompletionTime  int                 `json:"completionTime"`
+	Created         string              `json:"created"`
+	Status          string              `json:"status"`
+	Approved        bool                `json:"approved"`
+	Shadowed        bool                `json:"shadowed"`
+	Flags           []string            `json:"flags"`
+	RenamedFrom     string              `json:"renamedFrom,omitempty"`
+	DeletedAt       string              `json:"deletedAt,omitempty"`
+	Pending         *PendingImprovement `json:"pending,omitempty"`
+}
+
+// entryActionRequest is the body of a moderation action, JSON or form encoded
+type entryActionRequest struct {
+	Name   string `json:"name" form:"name"`
+	Reason string `json:"reason" form:"reason"`
+}
+
+func adminEntryFor(record *core.Record) AdminEntry {
+	entry := AdminEntry{
+		ID:              record.Id,
+		Name:            record.GetString("name"),
+		Identifier:      record.GetString("identifier"),
+		Score:           record.GetInt("score"),
+		LevelsCompleted: record.GetInt("levels_completed"),
+		CompletionTime:  record.GetInt("completion_time"),
+		Created:         record.GetDateTime("created").Time().Format(time.RFC3339),
+		Status:          statusApproved,
+		Approved:        record.GetBool("approved"),
+		Shadowed:        record.GetBool("shadowed"),
+		Flags:           []string{},
+		RenamedFrom:     record.GetString("renamed_from"),
+	}
+	_ = record.UnmarshalJSONField("flags", &entry.Flags)
+
+	if hasPendingImprovement(record) {
+		entry.Pending = &PendingImprovement{
+			Name:            record.GetString("pending_name"),
+			Score:           record.GetInt("pending_score"),
+			LevelsCompleted: record.GetInt("pending_levels_completed"),
+			CompletionTime:  record.GetInt("pending_completion_time"),
+			Flags:           []string{},
+			RenamedFrom:     record.GetString("pending_renamed_from"),
+			Submitted:       record.GetDateTime("pending_submitted").Time().Format(time.RFC3339),
+		}
+		_ = record.UnmarshalJSONField("pending_flags", &entry.Pending.Flags)
+	}
+
+	switch {
+	case isDeleted(record):
+		entry.Status = statusDeleted
+		entry.DeletedAt = record.GetDateTime("deleted_at").Time().Format(time.RFC3339)
+	case !entry.Approved || entry.Pending != nil:
+		entry.Status = statusPending
+	}
+
+	return entry
+}
+
+func adminEntriesFor(records []*core.Record) []AdminEntry {
+	entries := make([]AdminEntry, len(records))
+	for i, record := range records {
+		entries[i] = adminEntryFor(record)
+	}
+
+	return entries
+}
+
+// wantsJSON reports whether the client asked for JSON rather than a page
+func wantsJSON(e *core.RequestEvent) bool {
+	return strings.Contains(e.Request.Header.Get("Accept"), "application/json")
+}
+
+// verifyModerationPost checks a POST to one of the signed moderation links. Forms need their CSRF
+// token, JSON clients instead have to send a JSON body, which a page on another site can't do
+// without a CORS preflight that it won't pass.
+func (h *Handlers) verifyModerationPost(e *core.RequestEvent, form string) bool {
+	if wantsJSON(e) && strings.HasPrefix(e.Request.Header.Get("Content-Type"), "application/json") {
+		return true
+	}
+
+	return h.verifyCSRF(e, form)
+}
+
+func (h *Handlers) listEntries(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+
+	filter, ok := statusFilters[query.Get("status")]
+	if !ok {
+		return e.BadRequestError("Unknown status, use pending, approved, shadowed or deleted", nil)
+	}
+
+	limit := 100
+	if l := query.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+
+	params := dbx.Params{}
+	if identifier := query.Get("identifier"); identifier != "" {
+		filter += " && identifier = {:identifier}"
+		params["identifier"] = identifier
+	}
+
+	records, err := h.app.FindRecordsByFilter("leaderboard", filter, "-created", limit, max(offset, 0), params)
+	if err != nil {
+		return e.InternalServerError("Failed to fetch entries", err)
+	}
+
+	return e.JSON(http.StatusOK, adminEntriesFor(records))
+}
+
+func (h *Handlers) getEntry(e *core.RequestEvent) error {
+	record, err := h.app.FindRecordById("leaderboard", e.Request.PathValue("id"))
+	if err != nil {
+		return e.NotFoundError("Entry not found", err)
+	}
+
+	return e.JSON(http.StatusOK, adminEntryFor(record))
+}
+
+func (h *Handlers) approveEntryAPI(e *core.RequestEvent) error {
+	return h.entryAction(e, func(record *core.Record, req entryActionRequest) error {
+		return h.approveWithName(record, req.Name, e.Auth.Email(), req.Reason)
+	})
+}
+
+func (h *Handlers) deleteEntryAPI(e *core.RequestEvent) error {
+	return h.entryAction(e, func(record *core.Record, req entryActionRequest) error {
+		return h.app.RunInTransaction(func(txApp core.App) error {
+			_, err := deleteEntry(txApp, record, e.Auth.Email(), req.Reason)
+			return err
+		})
+	})
+}
+
+func (h *Handlers) renameEntryAPI(e *core.RequestEvent) error {
+	return h.entryAction(e, func(record *core.Record, req entryActionRequest) error {
+		name, err := h.moderatorName(req.Name)
+		if err != nil {
+			return err
+		}
+
+		return h.app.RunInTransaction(func(txApp core.App) error {
+			return renameEntry(txApp, record, name, e.Auth.Email(), req.Reason)
+		})
+	})
+}
+
+// entryAction runs a moderation action on the entry in the path and responds with the entry as it ends up
+func (h *Handlers) entryAction(e *core.RequestEvent, action func(*core.Record, entryActionRequest) error) error {
+	record, err := h.findActiveEntry(e.Request.PathValue("id"))
+	if err != nil {
+		return e.NotFoundError("Entry not found", err)
+	}
+
+	var req entryActionRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.BadRequestError("Invalid request body", err)
+	}
+
+	if err := action(record, req); err != nil {
+		return moderationError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, adminEntryFor(record))
+}
@@ -0,0 +1,203 @@
+// Package filter finds and masks offensive words in short user-supplied text such as player names.
+//
+// Word lists are kept per language. Text is normalised before matching, so leetspeak ("sh1t"),
+// stretched letters ("fuuuck"), spaced out letters ("f u c k") and look-alike characters from
+// other scripts all match the plain word. A locale's allow-list wins over every block list, which
+// is how a word that's offensive in one language but harmless in another is let through.
+package filter
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// WordList is the blocked and allowed words for one locale, such as "en" or "de"
+type WordList struct {
+	Locale string
+	Words  []string
+	Allow  []string
+}
+
+// Filter matches text against the compiled word lists. It's safe for concurrent use,
+// and Load can be called at any time to swap in new lists.
+type Filter struct {
+	mu             sync.RWMutex
+	locales        map[string]*compiledList
+	defaultLocales []string
+}
+
+type compiledList struct {
+	// blocked is keyed by the collapsed word (no repeated letters), several words can share a key
+	blocked map[string][]runs
+	allowed map[string]bool
+}
+
+// New returns an empty filter. The default locales always apply, on top of whatever a caller asks for.
+func New(defaultLocales ...string) *Filter {
+	return &Filter{
+		locales:        map[string]*compiledList{},
+		defaultLocales: defaultLocales,
+	}
+}
+
+// Load replaces every list the filter knows about. Lists for the same locale are merged.
+func (f *Filter) Load(lists []WordList) {
+	locales := map[string]*compiledList{}
+
+	for _, list := range lists {
+		locale := strings.ToLower(strings.TrimSpace(list.Locale))
+		compiled := locales[locale]
+		if compiled == nil {
+			compiled = &compiledList{blocked: map[string][]runs{}, allowed: map[string]bool{}}
+			locales[locale] = compiled
+		}
+
+		for _, word := range list.Words {
+			normalized := Normalize(word)
+			if normalized == "" {
+				continue
+			}
+			key := collapse(normalized)
+			compiled.blocked[key] = append(compiled.blocked[key], runLengths(normalized))
+		}
+
+		for _, word := range list.Allow {
+			if normalized := Normalize(word); normalized != "" {
+				compiled.allowed[collapse(normalized)] = true
+			}
+		}
+	}
+
+	f.mu.Lock()
+	f.locales = locales
+	f.mu.Unlock()
+}
+
+// Contains reports whether text has any blocked word for the given locales
+func (f *Filter) Contains(text string, locales ...string) bool {
+	return len(f.matches(text, locales)) > 0
+}
+
+// Censor replaces every letter of each blocked word with an asterisk, leaving the rest of text
+// (invisible characters inside the word included) alone
+func (f *Filter) Censor(text string, locales ...string) string {
+	spans := f.matches(text, locales)
+	if len(spans) == 0 {
+		return text
+	}
+
+	chars := []rune(text)
+	for _, s := range spans {
+		for i := s.start; i < s.end; i++ {
+			if isWordRune(chars[i]) && !unicode.Is(unicode.Cf, chars[i]) {
+				chars[i] = '*'
+			}
+		}
+	}
+
+	return string(chars)
+}
+
+// active picks the lists that apply for the requested locales. "de-AT" also uses "de".
+func (f *Filter) active(locales []string) []*compiledList {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	seen := map[string]bool{}
+	var lists []*compiledList
+	add := func(locale string) {
+		locale = strings.ToLower(strings.TrimSpace(locale))
+		if seen[locale] {
+			return
+		}
+		seen[locale] = true
+		if list := f.locales[locale]; list != nil {
+			lists = append(lists, list)
+		}
+	}
+
+	for _, locale := range slices.Concat(locales, f.defaultLocales) {
+		add(locale)
+		if base, _, ok := strings.Cut(locale, "-"); ok {
+			add(base)
+		}
+	}
+
+	return lists
+}
+
+func (f *Filter) matches(text string, locales []string) []span {
+	lists := f.active(locales)
+	if len(lists) == 0 {
+		return nil
+	}
+
+	blocked := func(normalized string) bool {
+		key := collapse(normalized)
+		for _, list := range lists {
+			if list.allowed[key] {
+				return false
+			}
+		}
+
+		word := runLengths(normalized)
+		for _, list := range lists {
+			for _, candidate := range list.blocked[key] {
+				if word.covers(candidate) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	var found []span
+	for _, group := range tokenize(text) {
+		// An ordinary word only matches as a whole, like a \b...\b regex would
+		if len(group) == 1 {
+			if blocked(group[0].normalized) || blocked(group[0].trimmed) {
+				found = append(found, group[0].span)
+			}
+			continue
+		}
+
+		// Spaced out letters are rejoined, and any run of them can spell a word
+		for i := range group {
+			var joined strings.Builder
+			for j := i; j < len(group); j++ {
+				joined.WriteString(group[j].normalized)
+				if j > i && blocked(joined.String()) {
+					found = append(found, span{group[i].start, group[j].end})
+				}
+			}
+		}
+	}
+
+	return found
+}
+
+// Parse reads a word list in the plain text format: one word per line, "!word" to allow a word,
+// blank lines and lines starting with "#" ignored
+func Parse(locale string, r io.Reader) (WordList, error) {
+	list := WordList{Locale: locale}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "!"):
+			list.Allow = append(list.Allow, strings.TrimSpace(line[1:]))
+		default:
+			list.Words = append(list.Words, line)
+		}
+	}
+
+	return list, scanner.Err()
+}
@@ -0,0 +1,96 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func testFilter(t *testing.T) *Filter {
+	t.Helper()
+
+	en, err := Parse("en", strings.NewReader("# English\nshit\nass\ndie\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	de, err := Parse("de", strings.NewReader("# German\n!die\nscheisse\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := New("en")
+	f.Load([]WordList{en, de})
+
+	return f
+}
+
+func TestCensor(t *testing.T) {
+	f := testFilter(t)
+
+	tests := []struct {
+		name    string
+		text    string
+		locales []string
+		want    string
+	}{
+		{"clean", "Sneaky Otter", nil, "Sneaky Otter"},
+		{"plain word", "big shit energy", nil, "big **** energy"},
+		{"upper case", "SHIT", nil, "****"},
+		{"leetspeak", "sh1t", nil, "****"},
+		{"stretched", "shiiiit", nil, "*******"},
+		{"spaced out", "s h i t", nil, "* * * *"},
+		{"look-alike", "ѕhit", nil, "****"},
+		{"whole words only", "assassin", nil, "assassin"},
+		{"dropped double letter", "as", nil, "as"},
+		{"punctuation kept", "shit.", nil, "****."},
+		{"blocked by the default locale", "Die Hard", nil, "*** Hard"},
+		{"allowed under de", "Die Hard", []string{"de"}, "Die Hard"},
+		{"de-AT falls back to de", "Die Hard", []string{"de-AT"}, "Die Hard"},
+		{"de list applies to de-AT", "scheisse", []string{"de-AT"}, "********"},
+		{"de list unused without de", "scheisse", nil, "scheisse"},
+		{"allow-list doesn't cover other words", "die shit", []string{"de"}, "die ****"},
+		{"zero-width space", "sh​it", nil, "**​**"},
+		{"zero-width joiner", "s‍h‍i‍t", nil, "*‍*‍*‍*"},
+		{"soft hyphen", "sh­it", nil, "**­**"},
+		{"invisible between spaced letters", "s ⁠h i t", nil, "* ⁠* * *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.Censor(tt.text, tt.locales...); got != tt.want {
+				t.Errorf("Censor(%q, %v) = %q, want %q", tt.text, tt.locales, got, tt.want)
+			}
+			if got := f.Contains(tt.text, tt.locales...); got != (tt.want != tt.text) {
+				t.Errorf("Contains(%q, %v) = %v, want %v", tt.text, tt.locales, got, tt.want != tt.text)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantWords []string
+		wantAllow []string
+	}{
+		{"empty", "", nil, nil},
+		{"comments and blank lines", "# comment\n\n  \nshit\n", []string{"shit"}, nil},
+		{"allow", "!die\n! das \n", nil, []string{"die", "das"}},
+		{"trimmed", "  shit  \n", []string{"shit"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := Parse("en", strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(list.Words, ",") != strings.Join(tt.wantWords, ",") {
+				t.Errorf("Words = %q, want %q", list.Words, tt.wantWords)
+			}
+			if strings.Join(list.Allow, ",") != strings.Join(tt.wantAllow, ",") {
+				t.Errorf("Allow = %q, want %q", list.Allow, tt.wantAllow)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Digits and symbols commonly swapped in for letters
+var leetspeak = map[rune]rune{
+	'0': 'o', '1': 'i', '2': 'z', '3': 'e', '4': 'a', '5': 's', '6': 'g', '7': 't', '8': 'b', '9': 'g',
+	'@': 'a', '$': 's', '!': 'i', '|': 'l', '+': 't', '€': 'e', '£': 'l',
+}
+
+// Letters from other scripts that look like latin ones. Compatibility forms (fullwidth,
+// mathematical alphanumerics, ligatures) are already handled by NFKD.
+var lookalikes = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'з': '3', 'і': 'i', 'ї': 'i', 'ј': 'j', 'к': 'k', 'м': 'm',
+	'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q',
+	'ԝ': 'w', 'ү': 'y', 'һ': 'h',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't',
+	'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// Latin letters that don't decompose
+	'ø': 'o', 'ł': 'l', 'đ': 'd', 'ħ': 'h', 'ı': 'i', 'ß': 's', 'æ': 'a', 'œ': 'o',
+}
+
+// Normalize folds text the way the filter sees it: lower case, accents and compatibility forms
+// removed, look-alikes and leetspeak mapped to plain latin letters, and everything else dropped
+func Normalize(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		b.WriteString(foldRune(r))
+	}
+
+	return b.String()
+}
+
+// foldRune normalises a single character, which may come out as nothing or as several letters
+func foldRune(r rune) string {
+	var b strings.Builder
+	for _, d := range norm.NFKD.String(string(r)) {
+		if unicode.Is(unicode.Mn, d) {
+			continue
+		}
+
+		d = unicode.ToLower(d)
+		if mapped, ok := lookalikes[d]; ok {
+			d = mapped
+		}
+		if mapped, ok := leetspeak[d]; ok {
+			d = mapped
+		}
+
+		if unicode.IsLetter(d) {
+			b.WriteRune(d)
+		}
+	}
+
+	return b.String()
+}
+
+// isWordRune is anything that can be part of a word, including the leetspeak symbols and
+// invisible format characters, so a zero-width space can't split a word in two
+func isWordRune(r rune) bool {
+	if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Cf) {
+		return true
+	}
+	_, ok := leetspeak[r]
+	return ok
+}
+
+type span struct {
+	start, end int // rune offsets into the original text
+}
+
+type token struct {
+	span
+	normalized string
+	trimmed    string // normalized without leetspeak symbols at either end, "bastard!!" being "bastard"
+}
+
+func isSymbol(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// tokenize splits text into words. Consecutive single letter words (as in "f u c k" or "f.u.c.k")
+// are grouped together so the caller can try joining them, every other word is a group of one.
+func tokenize(text string) [][]token {
+	var tokens []token
+
+	chars := []rune(text)
+	for i := 0; i < len(chars); {
+		if !isWordRune(chars[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(chars) && isWordRune(chars[i]) {
+			i++
+		}
+
+		coreStart, coreEnd := start, i
+		for coreStart < coreEnd && isSymbol(chars[coreStart]) {
+			coreStart++
+		}
+		for coreEnd > coreStart && isSymbol(chars[coreEnd-1]) {
+			coreEnd--
+		}
+
+		if normalized := Normalize(string(chars[start:i])); normalized != "" {
+			tokens = append(tokens, token{span{start, i}, normalized, Normalize(string(chars[coreStart:coreEnd]))})
+		}
+	}
+
+	var groups [][]token
+	for i := 0; i < len(tokens); {
+		j := i + 1
+		if len([]rune(tokens[i].normalized)) == 1 {
+			for j < len(tokens) && len([]rune(tokens[j].normalized)) == 1 && tokens[j].start-tokens[j-1].end <= 3 {
+				j++
+			}
+		}
+
+		groups = append(groups, tokens[i:j])
+		i = j
+	}
+
+	return groups
+}
+
+type run struct {
+	letter rune
+	count  int
+}
+
+// runs is a word as letters and how many times each repeats, "shiit" being s1 h1 i2 t1
+type runs []run
+
+func runLengths(word string) runs {
+	var result runs
+	for _, r := range word {
+		if n := len(result); n > 0 && result[n-1].letter == r {
+			result[n-1].count++
+			continue
+		}
+		result = append(result, run{r, 1})
+	}
+
+	return result
+}
+
+// covers reports whether w is the blocked word stretched out. Repeating letters matches
+// ("fuuuck" covers "fuck") but dropping doubled ones doesn't, so "as" never matches "ass".
+func (w runs) covers(blocked runs) bool {
+	if len(w) != len(blocked) {
+		return false
+	}
+
+	for i := range w {
+		if w[i].letter != blocked[i].letter || w[i].count < blocked[i].count {
+			return false
+		}
+	}
+
+	return true
+}
+
+// collapse drops repeated letters, so every stretched spelling of a word shares a key
+func collapse(word string) string {
+	var b strings.Builder
+	var last rune = -1
+	for _, r := range word {
+		if r != last {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+
+	return b.String()
+}
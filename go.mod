@@ -7,6 +7,7 @@ toolchain go1.24.6
 require (
 	github.com/joho/godotenv v1.5.1
 	github.com/pocketbase/pocketbase v0.29.3
+	github.com/spf13/cobra v1.9.1
 )
 
 require (
@@ -37,7 +38,6 @@ require (
 	github.com/pocketbase/tygoja v0.0.0-20250812183945-97ffe055281f // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/spf13/cast v1.9.2 // indirect
-	github.com/spf13/cobra v1.9.1 // indirect
 	github.com/spf13/pflag v1.0.7 // indirect
 	github.com/stretchr/testify v1.8.1 // indirect
 	golang.org/x/crypto v0.41.0 // indirect
@@ -1,25 +1,37 @@
 package main
 
 import (
+	"cookie-banner-clicker/filter"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
 type Handlers struct {
 	app          *pocketbase.PocketBase
 	emailService *EmailService
+	notifiers    []Notifier
+	outboxMu     sync.Mutex
+	nameFilter   *filter.Filter
+
+	wordListsMu      sync.Mutex
+	wordListsVersion string // what the word list files looked like when last loaded
 }
 
 type LeaderboardEntry struct {
@@ -36,6 +48,7 @@ type PlayerStats struct {
 	Rank         *int              `json:"rank"`
 	TotalPlayers int               `json:"totalPlayers"`
 	Entry        *LeaderboardEntry `json:"entry"`
+	RenamedFrom  string            `json:"renamedFrom,omitempty"` // Set when a moderator changed the player's name
 }
 
 type SubmitScoreRequest struct {
@@ -47,22 +60,64 @@ type SubmitScoreRequest struct {
 }
 
 func NewHandlers(app *pocketbase.PocketBase) *Handlers {
+	emailService := NewEmailService(app)
+
 	return &Handlers{
 		app:          app,
-		emailService: NewEmailService(app),
+		emailService: emailService,
+		notifiers:    newNotifiers(app, emailService),
+		nameFilter:   filter.New(defaultFilterLocale()),
 	}
 }
 
 func (h *Handlers) RegisterRoutes(se *core.ServeEvent) {
-	se.Router.GET("/api/leaderboard", h.getLeaderboard)
-	se.Router.GET("/api/leaderboard/player/{identifier}", h.getPlayerStats)
-	se.Router.POST("/api/leaderboard/submit", h.submitScore)
+	se.Router.GET("/api/leaderboard", h.getLeaderboard).Bind(measureLatency("leaderboard"))
+	se.Router.GET("/api/leaderboard/player/{identifier}", h.getPlayerStats).Bind(measureLatency("player"))
+	se.Router.POST("/api/leaderboard/submit", h.submitScore).Bind(measureLatency("submit"))
 
-	// Signed admin endpoints for email links - these are the only way to moderate
+	// Signed admin endpoints for email links, answering in JSON for Accept: application/json
 	se.Router.GET("/admin/approve/{id}/{signature}", h.signedApproveScore)
 	se.Router.POST("/admin/approve/{id}/{signature}", h.signedApproveScore)
 	se.Router.GET("/admin/delete/{id}/{signature}", h.signedDeleteScore)
 	se.Router.POST("/admin/delete/{id}/{signature}", h.signedDeleteScore)
+	se.Router.GET("/admin/approve-all/{ids}/{issued}/{signature}", h.signedApproveAll)
+	se.Router.POST("/admin/approve-all/{ids}/{issued}/{signature}", h.signedApproveAll)
+	se.Router.GET("/admin/restore/{id}/{signature}", h.signedRestoreScore)
+	se.Router.POST("/admin/restore/{id}/{signature}", h.signedRestoreScore)
+
+	// Moderation history, the page reads from the superuser-only JSON endpoint
+	se.Router.GET("/admin/moderation-log", h.moderationLogPage)
+	se.Router.GET("/api/admin/moderation-log", h.getModerationLog).Bind(apis.RequireSuperuserAuth())
+
+	// Notification deliveries, mostly for finding and resending failed ones
+	se.Router.GET("/admin/outbox", h.outboxPage)
+	se.Router.GET("/api/admin/outbox", h.getOutbox).Bind(apis.RequireSuperuserAuth())
+	se.Router.POST("/api/admin/outbox/{id}/resend", h.resendOutbox).Bind(apis.RequireSuperuserAuth())
+
+	// The same moderation actions as JSON, for scripts and other clients
+	se.Router.GET("/api/admin/entries", h.listEntries).Bind(apis.RequireSuperuserAuth())
+	se.Router.GET("/api/admin/entries/{id}", h.getEntry).Bind(apis.RequireSuperuserAuth())
+	se.Router.POST("/api/admin/entries/{id}/approve", h.approveEntryAPI).Bind(apis.RequireSuperuserAuth())
+	se.Router.POST("/api/admin/entries/{id}/delete", h.deleteEntryAPI).Bind(apis.RequireSuperuserAuth())
+	se.Router.POST("/api/admin/entries/{id}/rename", h.renameEntryAPI).Bind(apis.RequireSuperuserAuth())
+
+	// Moving entries between instances, identifiers are never exported
+	se.Router.GET("/api/admin/export", h.exportLeaderboard).Bind(apis.RequireSuperuserAuth())
+	se.Router.POST("/api/admin/import", h.importLeaderboard).Bind(apis.RequireSuperuserAuth())
+
+	// Soft-deleted entries waiting to be purged
+	se.Router.GET("/admin/trash", h.trashPage)
+	se.Router.GET("/api/admin/trash", h.getTrash).Bind(apis.RequireSuperuserAuth())
+	se.Router.POST("/api/admin/trash/{id}/restore", h.restoreFromTrash).Bind(apis.RequireSuperuserAuth())
+
+	// Renders the moderation email for an entry as the calling superuser would get it
+	se.Router.GET("/admin/mail/preview/{id}", h.previewModerationMail).Bind(apis.RequireSuperuserAuth())
+
+	// Replies to moderation emails, posted raw by an MTA pipe or mail provider webhook
+	se.Router.POST("/api/inbound/mail", h.receiveMail)
+
+	// Prometheus scrapes, see metrics.go for who gets in
+	se.Router.GET("/metrics", h.serveMetrics)
 }
 
 func (h *Handlers) getLeaderboard(e *core.RequestEvent) error {
@@ -75,7 +130,7 @@ func (h *Handlers) getLeaderboard(e *core.RequestEvent) error {
 
 	records, err := h.app.FindRecordsByFilter(
 		"leaderboard",
-		"approved = true",
+		"approved = true && shadowed = false && deleted_at = ''",
 		"-score",
 		limit,
 		0,
@@ -106,15 +161,17 @@ func (h *Handlers) getPlayerStats(e *core.RequestEvent) error {
 	// Get player's entry
 	playerRecords, err := h.app.FindRecordsByFilter(
 		"leaderboard",
-		fmt.Sprintf("identifier = '%s' && approved = true", identifier),
+		fmt.Sprintf("identifier = '%s' && approved = true && deleted_at = ''", identifier),
 		"-score",
 		1,
 		0,
 	)
 
 	var playerEntry *LeaderboardEntry
+	renamedFrom := ""
 	if err == nil && len(playerRecords) > 0 {
 		record := playerRecords[0]
+		renamedFrom = record.GetString("renamed_from")
 		playerEntry = &LeaderboardEntry{
 			ID:              record.Id,
 			Name:            record.GetString("name"),
@@ -137,7 +194,7 @@ func (h *Handlers) getPlayerStats(e *core.RequestEvent) error {
 	if playerEntry != nil {
 		betterScores, err := h.app.FindRecordsByFilter(
 			"leaderboard",
-			fmt.Sprintf("score > %d && approved = true", playerEntry.Score),
+			fmt.Sprintf("score > %d && approved = true && shadowed = false && deleted_at = ''", playerEntry.Score),
 			"",
 			1,
 			0,
@@ -152,6 +209,7 @@ func (h *Handlers) getPlayerStats(e *core.RequestEvent) error {
 		Rank:         rank,
 		TotalPlayers: totalCount,
 		Entry:        playerEntry,
+		RenamedFrom:  renamedFrom,
 	}
 
 	return e.JSON(http.StatusOK, stats)
@@ -160,20 +218,61 @@ func (h *Handlers) getPlayerStats(e *core.RequestEvent) error {
 func (h *Handlers) submitScore(e *core.RequestEvent) error {
 	var req SubmitScoreRequest
 	if err := e.BindBody(&req); err != nil {
+		metrics.submissions.inc(submissionInvalid)
 		return e.BadRequestError("Invalid request body", err)
 	}
 
 	// Sanitize name
-	sanitizedName := h.sanitizeName(req.Name)
+	locales := requestLocales(e)
+	sanitizedName := h.sanitizeName(req.Name, locales...)
 	if sanitizedName == "" {
+		metrics.submissions.inc(submissionInvalid)
 		return e.BadRequestError("Invalid name", nil)
 	}
 
 	// Validate score (basic sanity check)
-	if req.Score < 0 || req.Score > 10000 || req.LevelsCompleted < 0 || req.LevelsCompleted > 20 {
+	if !scoreInBounds(req.Score, req.LevelsCompleted) {
+		metrics.submissions.inc(submissionInvalid)
 		return e.BadRequestError("Invalid score or levels", nil)
 	}
 
+	// Banned players are either refused or quietly kept out of moderation and the public board
+	ban, err := h.findActiveBan(req.Identifier, sanitizedName, req.Name)
+	if err != nil {
+		log.Printf("failed to check bans: %v", err)
+	}
+	if ban != nil && ban.GetString("mode") == banModeReject {
+		metrics.submissions.inc(submissionInvalid)
+		return e.ForbiddenError("You are not allowed to submit scores", nil)
+	}
+	shadowed := ban != nil && ban.GetString("mode") == banModeShadow
+
+	// Nobody gets to pass themselves off as a top player, an admin or the game
+	reserved, err := h.findReservedName(sanitizedName, req.Identifier)
+	if err != nil {
+		log.Printf("failed to check reserved names: %v", err)
+	}
+	if reserved != "" && reservedNameAction() == reservedReject {
+		metrics.submissions.inc(submissionInvalid)
+		return e.JSON(http.StatusConflict, map[string]interface{}{
+			"message":       "That name is taken or too close to one that is, please pick a different one",
+			"success":       false,
+			"suggestedName": h.suggestName(sanitizedName, req.Identifier),
+		})
+	}
+
+	candidate := approvalCandidate{
+		Score:     req.Score,
+		NameClean: sanitizedName == normalizeName(req.Name),
+		Flags:     detectAnomalies(req.Score, req.LevelsCompleted, req.CompletionTime),
+	}
+	if hasMixedScriptWord(sanitizedName) {
+		candidate.Flags = append(candidate.Flags, flagMixedScript)
+	}
+	if reserved != "" {
+		candidate.Flags = append(candidate.Flags, flagReservedName)
+	}
+
 	// Check if player already exists
 	existingRecords, err := h.app.FindRecordsByFilter(
 		"leaderboard",
@@ -190,28 +289,72 @@ func (h *Handlers) submitScore(e *core.RequestEvent) error {
 		return e.InternalServerError("Failed to find collection", err)
 	}
 
+	// A deleted player stays out until a moderator restores the entry or the purge job removes it,
+	// resubmitting mustn't clear the trashed entry (and its moderation history) out of the way
+	if len(existingRecords) > 0 && isDeleted(existingRecords[0]) {
+		metrics.submissions.inc(submissionInvalid)
+		return e.ForbiddenError("Your entry was removed by a moderator", nil)
+	}
+
 	if len(existingRecords) > 0 {
 		// Update existing record if better score
 		existing := existingRecords[0]
-		if existing.GetInt("score") < req.Score {
-			existing.Set("name", sanitizedName)
-			existing.Set("score", req.Score)
-			existing.Set("levels_completed", req.LevelsCompleted)
-			existing.Set("completion_time", req.CompletionTime)
-			existing.Set("approved", false) // Require re-approval
-
-			if err := h.app.Save(existing); err != nil {
-				return e.InternalServerError("Failed to update score", err)
+		if bestKnownScore(existing) < req.Score {
+			if existing.GetBool("approved") {
+				// Keep the published score visible and park the improvement until it's approved
+				existing.Set("pending_name", sanitizedName)
+				existing.Set("pending_name_locales", joinLocales(locales))
+				existing.Set("pending_score", req.Score)
+				existing.Set("pending_levels_completed", req.LevelsCompleted)
+				existing.Set("pending_completion_time", req.CompletionTime)
+				existing.Set("pending_flags", candidate.Flags)
+				existing.Set("pending_renamed_from", "") // The player picked a name again
+				existing.Set("pending_submitted", types.NowDateTime())
+			} else {
+				// Nothing published yet, so just replace the unapproved submission
+				clearPendingImprovement(existing)
+				existing.Set("name", sanitizedName)
+				existing.Set("name_locales", joinLocales(locales))
+				existing.Set("score", req.Score)
+				existing.Set("levels_completed", req.LevelsCompleted)
+				existing.Set("completion_time", req.CompletionTime)
+				existing.Set("flags", candidate.Flags)
+				existing.Set("renamed_from", "") // The player picked a name again
+			}
+			existing.Set("shadowed", shadowed)
+
+			candidate.PreviouslyApproved = existing.GetBool("approved")
+			var rule *core.Record
+			if !shadowed && reserved == "" {
+				rule = h.approvalRuleFor(candidate)
+			}
+			if rule != nil {
+				autoApprove(existing, rule)
 			}
 
-			// Send moderation email async
-			go h.emailService.SendModerationEmail(existing.Id, sanitizedName, req.Score, req.LevelsCompleted, "", false, h)
+			notice := ModerationNotice{
+				Name:            sanitizedName,
+				Score:           req.Score,
+				LevelsCompleted: req.LevelsCompleted,
+				CompletionTime:  req.CompletionTime,
+				Flags:           candidate.Flags,
+				ReservedName:    reserved,
+				IsNew:           false,
+			}
+			if existing.GetBool("approved") {
+				notice.PublishedScore = existing.GetInt("score")
+			}
+			if err := h.saveSubmission(existing, rule, !shadowed, notice); err != nil {
+				return e.InternalServerError("Failed to update score", err)
+			}
+			metrics.submissions.inc(submissionImproved)
 
 			return e.JSON(http.StatusOK, map[string]interface{}{
 				"message": "Score updated successfully",
 				"success": true,
 			})
 		}
+		metrics.submissions.inc(submissionNotBest)
 		return e.JSON(http.StatusOK, map[string]interface{}{
 			"message": "Existing score is better",
 			"success": false,
@@ -221,18 +364,36 @@ func (h *Handlers) submitScore(e *core.RequestEvent) error {
 	// Create new record
 	record := core.NewRecord(collection)
 	record.Set("name", sanitizedName)
+	record.Set("name_locales", joinLocales(locales))
 	record.Set("identifier", req.Identifier)
 	record.Set("score", req.Score)
 	record.Set("levels_completed", req.LevelsCompleted)
 	record.Set("completion_time", req.CompletionTime)
 	record.Set("approved", false) // Require approval
+	record.Set("flags", candidate.Flags)
+	record.Set("shadowed", shadowed)
 
-	if err := h.app.Save(record); err != nil {
-		return e.InternalServerError("Failed to save score", err)
+	var rule *core.Record
+	if !shadowed && reserved == "" {
+		rule = h.approvalRuleFor(candidate)
+	}
+	if rule != nil {
+		autoApprove(record, rule)
 	}
 
-	// Send moderation email async
-	go h.emailService.SendModerationEmail(record.Id, sanitizedName, req.Score, req.LevelsCompleted, "", true, h)
+	notice := ModerationNotice{
+		Name:            sanitizedName,
+		Score:           req.Score,
+		LevelsCompleted: req.LevelsCompleted,
+		CompletionTime:  req.CompletionTime,
+		Flags:           candidate.Flags,
+		ReservedName:    reserved,
+		IsNew:           true,
+	}
+	if err := h.saveSubmission(record, rule, !shadowed, notice); err != nil {
+		return e.InternalServerError("Failed to save score", err)
+	}
+	metrics.submissions.inc(submissionNew)
 
 	return e.JSON(http.StatusOK, map[string]interface{}{
 		"message": "Score submitted successfully",
@@ -245,264 +406,364 @@ func (h *Handlers) signedApproveScore(e *core.RequestEvent) error {
 	id := e.Request.PathValue("id")
 	signature := e.Request.PathValue("signature")
 
-	if !h.verifySignature("approve", id, signature) {
+	recipient := e.Request.URL.Query().Get("moderator")
+
+	if !h.verifySignature("approve", id, recipient, signature) {
+		metrics.signatureFailures.inc("approve")
 		return e.BadRequestError("Invalid signature", nil)
 	}
 
 	// Check if this is the confirmation (POST request)
 	if e.Request.Method == "POST" {
-		return h.doApproveScore(e, id)
+		if !h.verifyModerationPost(e, "approve:"+id) {
+			return csrfError(e)
+		}
+		return h.doApproveScore(e, id, moderatorIdentity(e, recipient))
 	}
 
 	// Show confirmation page (GET request)
-	record, err := h.app.FindRecordById("leaderboard", id)
+	record, err := h.findActiveEntry(id)
 	if err != nil {
 		return e.NotFoundError("Score not found", err)
 	}
 
-	html := fmt.Sprintf(`<!DOCTYPE html>
-<html>
-<head>
-    <title>Approve Score - Cookie Banner Clicker</title>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <style>
-        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; max-width: 600px; margin: 50px auto; padding: 20px; background: #f5f5f5; }
-        .card { background: white; padding: 30px; border-radius: 10px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
-        .header { text-align: center; margin-bottom: 30px; }
-        .score-details { background: #f8f9fa; padding: 20px; border-radius: 5px; margin: 20px 0; }
-        .buttons { display: flex; gap: 15px; justify-content: center; margin-top: 30px; }
-        .btn { padding: 12px 30px; border: none; border-radius: 5px; font-size: 16px; cursor: pointer; text-decoration: none; display: inline-block; text-align: center; }
-        .btn-approve { background: #28a745; color: white; }
-        .btn-approve:hover { background: #218838; }
-        .btn-cancel { background: #6c757d; color: white; }
-        .btn-cancel:hover { background: #5a6268; }
-    </style>
-</head>
-<body>
-    <div class="card">
-        <div class="header">
-            <h1>🏆 Approve Leaderboard Score</h1>
-            <p>Review this submission before approving it for the public leaderboard.</p>
-        </div>
-        
-        <div class="score-details">
-            <h3>Score Details:</h3>
-            <p><strong>Player Name:</strong> %s</p>
-            <p><strong>Score:</strong> %d points</p>
-            <p><strong>Levels Completed:</strong> %d/20</p>
-            <p><strong>Completion Time:</strong> %d seconds</p>
-            <p><strong>Submitted:</strong> %s</p>
-        </div>
-        
-        <div class="buttons">
-            <form method="POST" style="display: inline;">
-                <button type="submit" class="btn btn-approve">✅ Approve Score</button>
-            </form>
-            <a href="javascript:window.close()" class="btn btn-cancel">❌ Cancel</a>
-        </div>
-        
-        <p style="text-align: center; margin-top: 30px; font-size: 14px; color: #666;">
-            This link is secure and can only be accessed by authorized administrators.
-        </p>
-    </div>
-</body>
-</html>`,
-		record.GetString("name"),
-		record.GetInt("score"),
-		record.GetInt("levels_completed"),
-		record.GetInt("completion_time")/1000,
-		record.GetDateTime("created").Time().Format("2006-01-02 15:04:05"))
-
-	return e.String(http.StatusOK, html)
+	if wantsJSON(e) {
+		return e.JSON(http.StatusOK, adminEntryFor(record))
+	}
+
+	return renderPage(e, http.StatusOK, "approve", moderationPageData{
+		Details: submissionDetailsFor(record),
+		CSRF:    h.csrfToken(e, "approve:"+id),
+	})
 }
 
 func (h *Handlers) signedDeleteScore(e *core.RequestEvent) error {
 	id := e.Request.PathValue("id")
 	signature := e.Request.PathValue("signature")
 
-	if !h.verifySignature("delete", id, signature) {
+	recipient := e.Request.URL.Query().Get("moderator")
+
+	if !h.verifySignature("delete", id, recipient, signature) {
+		metrics.signatureFailures.inc("delete")
 		return e.BadRequestError("Invalid signature", nil)
 	}
 
 	// Check if this is the confirmation (POST request)
 	if e.Request.Method == "POST" {
-		return h.doDeleteScore(e, id)
+		if !h.verifyModerationPost(e, "delete:"+id) {
+			return csrfError(e)
+		}
+		return h.doDeleteScore(e, id, moderatorIdentity(e, recipient))
 	}
 
 	// Show confirmation page (GET request)
-	record, err := h.app.FindRecordById("leaderboard", id)
+	record, err := h.findActiveEntry(id)
 	if err != nil {
 		return e.NotFoundError("Score not found", err)
 	}
 
-	html := fmt.Sprintf(`<!DOCTYPE html>
-<html>
-<head>
-    <title>Delete Score - Cookie Banner Clicker</title>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <style>
-        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; max-width: 600px; margin: 50px auto; padding: 20px; background: #f5f5f5; }
-        .card { background: white; padding: 30px; border-radius: 10px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
-        .header { text-align: center; margin-bottom: 30px; }
-        .score-details { background: #f8f9fa; padding: 20px; border-radius: 5px; margin: 20px 0; }
-        .warning { background: #fff3cd; border: 1px solid #ffeaa7; padding: 15px; border-radius: 5px; margin: 20px 0; color: #856404; }
-        .buttons { display: flex; gap: 15px; justify-content: center; margin-top: 30px; }
-        .btn { padding: 12px 30px; border: none; border-radius: 5px; font-size: 16px; cursor: pointer; text-decoration: none; display: inline-block; text-align: center; }
-        .btn-delete { background: #dc3545; color: white; }
-        .btn-delete:hover { background: #c82333; }
-        .btn-cancel { background: #6c757d; color: white; }
-        .btn-cancel:hover { background: #5a6268; }
-    </style>
-</head>
-<body>
-    <div class="card">
-        <div class="header">
-            <h1>🗑️ Delete Leaderboard Score</h1>
-            <p>Review this submission before permanently removing it.</p>
-        </div>
-        
-        <div class="score-details">
-            <h3>Score Details:</h3>
-            <p><strong>Player Name:</strong> %s</p>
-            <p><strong>Score:</strong> %d points</p>
-            <p><strong>Levels Completed:</strong> %d/20</p>
-            <p><strong>Completion Time:</strong> %d seconds</p>
-            <p><strong>Submitted:</strong> %s</p>
-        </div>
-        
-        <div class="warning">
-            <strong>⚠️ Warning:</strong> This action cannot be undone. The score will be permanently removed from the leaderboard.
-        </div>
-        
-        <div class="buttons">
-            <form method="POST" style="display: inline;">
-                <button type="submit" class="btn btn-delete" onclick="return confirm('Are you sure you want to delete this score? This action cannot be undone.')">🗑️ Delete Score</button>
-            </form>
-            <a href="javascript:window.close()" class="btn btn-cancel">❌ Cancel</a>
-        </div>
-        
-        <p style="text-align: center; margin-top: 30px; font-size: 14px; color: #666;">
-            This link is secure and can only be accessed by authorized administrators.
-        </p>
-    </div>
-</body>
-</html>`,
-		record.GetString("name"),
-		record.GetInt("score"),
-		record.GetInt("levels_completed"),
-		record.GetInt("completion_time")/1000,
-		record.GetDateTime("created").Time().Format("2006-01-02 15:04:05"))
-
-	return e.String(http.StatusOK, html)
+	if wantsJSON(e) {
+		return e.JSON(http.StatusOK, adminEntryFor(record))
+	}
+
+	return renderPage(e, http.StatusOK, "delete", moderationPageData{
+		Details:       submissionDetailsFor(record),
+		CSRF:          h.csrfToken(e, "delete:"+id),
+		RetentionDays: trashRetentionDays(),
+	})
 }
 
 // Actual action functions called after confirmation
-func (h *Handlers) doApproveScore(e *core.RequestEvent, id string) error {
-	record, err := h.app.FindRecordById("leaderboard", id)
+func (h *Handlers) doApproveScore(e *core.RequestEvent, id, moderator string) error {
+	record, err := h.findActiveEntry(id)
 	if err != nil {
 		return e.NotFoundError("Score not found", err)
 	}
 
-	record.Set("approved", true)
-	if err := h.app.Save(record); err != nil {
-		return e.InternalServerError("Failed to approve score", err)
-	}
-
-	html := `<!DOCTYPE html>
-<html>
-<head>
-    <title>Score Approved - Cookie Banner Clicker</title>
-    <style>
-        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; max-width: 400px; margin: 100px auto; padding: 40px; text-align: center; background: #f5f5f5; }
-        .success { background: white; padding: 40px; border-radius: 10px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
-        .icon { font-size: 64px; margin-bottom: 20px; }
-        h1 { color: #28a745; margin-bottom: 10px; }
-    </style>
-</head>
-<body>
-    <div class="success">
-        <div class="icon">✅</div>
-        <h1>Score Approved!</h1>
-        <p>The leaderboard entry has been approved and is now visible on the public leaderboard.</p>
-        <button onclick="window.close()" style="margin-top: 20px; padding: 10px 20px; background: #28a745; color: white; border: none; border-radius: 5px; cursor: pointer;">Close</button>
-    </div>
-</body>
-</html>`
-
-	return e.String(http.StatusOK, html)
-}
-
-func (h *Handlers) doDeleteScore(e *core.RequestEvent, id string) error {
-	record, err := h.app.FindRecordById("leaderboard", id)
+	var req entryActionRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.BadRequestError("Invalid request body", err)
+	}
+
+	if err := h.approveWithName(record, req.Name, moderator, req.Reason); err != nil {
+		return moderationError(e, err)
+	}
+
+	if wantsJSON(e) {
+		return e.JSON(http.StatusOK, adminEntryFor(record))
+	}
+
+	data := approvedPageData{Count: 1}
+	if req.Name != "" {
+		data.RenamedTo = record.GetString("name")
+	}
+
+	return renderPage(e, http.StatusOK, "approved", data)
+}
+
+func (h *Handlers) doDeleteScore(e *core.RequestEvent, id, moderator string) error {
+	record, err := h.findActiveEntry(id)
 	if err != nil {
 		return e.NotFoundError("Score not found", err)
 	}
 
-	if err := h.app.Delete(record); err != nil {
+	var req entryActionRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.BadRequestError("Invalid request body", err)
+	}
+
+	var discarded bool
+	err = h.app.RunInTransaction(func(txApp core.App) error {
+		var err error
+		discarded, err = deleteEntry(txApp, record, moderator, req.Reason)
+		return err
+	})
+	if err != nil {
 		return e.InternalServerError("Failed to delete score", err)
 	}
 
-	html := `<!DOCTYPE html>
-<html>
-<head>
-    <title>Score Deleted - Cookie Banner Clicker</title>
-    <style>
-        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; max-width: 400px; margin: 100px auto; padding: 40px; text-align: center; background: #f5f5f5; }
-        .success { background: white; padding: 40px; border-radius: 10px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
-        .icon { font-size: 64px; margin-bottom: 20px; }
-        h1 { color: #dc3545; margin-bottom: 10px; }
-    </style>
-</head>
-<body>
-    <div class="success">
-        <div class="icon">🗑️</div>
-        <h1>Score Deleted</h1>
-        <p>The leaderboard entry has been permanently removed from the database.</p>
-        <button onclick="window.close()" style="margin-top: 20px; padding: 10px 20px; background: #dc3545; color: white; border: none; border-radius: 5px; cursor: pointer;">Close</button>
-    </div>
-</body>
-</html>`
+	if wantsJSON(e) {
+		return e.JSON(http.StatusOK, adminEntryFor(record))
+	}
+
+	data := deletedPageData{Discarded: discarded, RetentionDays: trashRetentionDays()}
+	if !discarded {
+		// Signed for whoever deleted it, so the restore is credited to them too
+		data.UndoURL = signedModerationURL(h.app, "restore", id, moderator, h)
+		data.UndoCSRF = h.csrfToken(e, "restore:"+id)
+	}
+
+	return renderPage(e, http.StatusOK, "deleted", data)
+}
+
+var errInvalidName = fmt.Errorf("invalid name, it needs at least %d characters that get through the filter", minNameGraphemes)
+
+// moderatorName sanitizes a name a moderator typed. A player whose name doesn't survive the filter
+// quietly becomes anonymousName, a moderator is told instead so they can pick another.
+func (h *Handlers) moderatorName(name string) (string, error) {
+	sanitized := h.sanitizeName(name)
+	if sanitized == "" || (sanitized == anonymousName && normalizeName(strings.TrimSpace(name)) != anonymousName) {
+		return "", errInvalidName
+	}
 
-	return e.String(http.StatusOK, html)
+	return sanitized, nil
 }
 
-func (h *Handlers) sanitizeName(name string) string {
-	// Basic profanity filter
-	profanityList := []string{
-		"fuck", "shit", "damn", "bitch", "asshole", "bastard", "crap", "piss",
-		"penis", "vagina", "sex", "nazi", "hitler", "kill", "die", "suicide",
+// approveWithName approves an entry, first renaming it if a moderator gave a replacement name.
+// The new name is held to the same rules as the player's own.
+func (h *Handlers) approveWithName(record *core.Record, name, moderator, reason string) error {
+	newName := ""
+	if name != "" {
+		var err error
+		if newName, err = h.moderatorName(name); err != nil {
+			return err
+		}
 	}
 
-	// Remove special characters and limit length
-	re := regexp.MustCompile(`[^a-zA-Z0-9\s]`)
-	sanitized := re.ReplaceAllString(strings.TrimSpace(name), "")
+	return h.app.RunInTransaction(func(txApp core.App) error {
+		if newName != "" {
+			if err := renameEntry(txApp, record, newName, moderator, reason); err != nil {
+				return err
+			}
+		}
+
+		return approveEntry(txApp, record, moderator, reason)
+	})
+}
 
-	if len(sanitized) > 20 {
-		sanitized = sanitized[:20]
+func moderationError(e *core.RequestEvent, err error) error {
+	if errors.Is(err, errInvalidName) {
+		return e.BadRequestError(fmt.Sprintf("Invalid name, it needs at least %d characters that get through the filter", minNameGraphemes), nil)
 	}
 
-	sanitized = strings.TrimSpace(sanitized)
+	return e.InternalServerError("Failed to update score", err)
+}
+
+// sanitizeName censors the name with the word lists for the given locales (the player's languages)
+// on top of the default ones, then strips it down to the characters names may use. Letters from
+// any script are fine, emoji follow NAME_EMOJI, and an empty result means the name was refused.
+func (h *Handlers) sanitizeName(name string, locales ...string) string {
+	// Normalise first so "e" + combining accent and "é" are the same name, and hidden characters
+	// can't split a word to sneak it past the filter
+	normalized := normalizeName(name)
+
+	// Censor before stripping, the filter understands leetspeak that stripping would mangle
+	censored := h.nameFilter.Censor(normalized, locales...)
 
-	// Replace profanity
-	for _, word := range profanityList {
-		re := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(word) + `\b`)
-		sanitized = re.ReplaceAllString(sanitized, strings.Repeat("*", len(word)))
+	policy := nameEmojiPolicy()
+	sanitized, hadEmoji := cleanNameChars(censored, policy)
+	if hadEmoji && policy == emojiReject {
+		return ""
+	}
+
+	sanitized = strings.TrimSpace(truncateGraphemes(sanitized, maxNameGraphemes))
+	if utf8.RuneCountInString(sanitized) > maxNameRunes {
+		return ""
 	}
 
 	// Default name if empty or too short
-	if len(sanitized) < 2 {
-		return "Anonymous Player"
+	if len(graphemes(sanitized)) < minNameGraphemes {
+		return anonymousName
 	}
 
 	return sanitized
 }
 
+// approveEntry publishes an entry (or its pending improvement) on behalf of a moderator and logs it
+func approveEntry(txApp core.App, record *core.Record, moderator, reason string) error {
+	if err := logModeration(txApp, actionApprove, record, moderator, reason); err != nil {
+		return err
+	}
+
+	if hasPendingImprovement(record) {
+		applyPendingImprovement(record)
+	}
+
+	record.Set("approved", true)
+	record.Set("approval_rule", "") // Approved by a human
+	return txApp.Save(record)
+}
+
+// deleteEntry moves an entry to the trash on behalf of a moderator and logs it. If the entry has an approved
+// score with an improvement pending, only the improvement is discarded and discarded is true.
+func deleteEntry(txApp core.App, record *core.Record, moderator, reason string) (discarded bool, err error) {
+	if hasPendingImprovement(record) {
+		if err := logModeration(txApp, actionDiscardImprovement, record, moderator, reason); err != nil {
+			return false, err
+		}
+
+		clearPendingImprovement(record)
+		return true, txApp.Save(record)
+	}
+
+	if err := logModeration(txApp, actionDelete, record, moderator, reason); err != nil {
+		return false, err
+	}
+
+	record.Set("deleted_at", types.NowDateTime())
+	return false, txApp.Save(record)
+}
+
+// restoreEntry takes an entry back out of the trash as it was when it was deleted
+func restoreEntry(txApp core.App, record *core.Record, moderator, reason string) error {
+	if err := logModeration(txApp, actionRestore, record, moderator, reason); err != nil {
+		return err
+	}
+
+	record.Set("deleted_at", "")
+	return txApp.Save(record)
+}
+
+// renameEntry replaces the name under review (the pending improvement's, if there is one) and logs
+// it, so the moderation log keeps the player's original name in its snapshot. The entry remembers
+// the name the player chose so they can be told it was changed.
+func renameEntry(txApp core.App, record *core.Record, name, moderator, reason string) error {
+	if err := logModeration(txApp, actionRename, record, moderator, reason); err != nil {
+		return err
+	}
+
+	field, renamedFrom := "name", "renamed_from"
+	if hasPendingImprovement(record) {
+		field, renamedFrom = "pending_name", "pending_renamed_from"
+	}
+
+	if record.GetString(renamedFrom) == "" {
+		record.Set(renamedFrom, record.GetString(field))
+	}
+	record.Set(field, name)
+	record.Set(field+"_locales", "") // Moderators' names only go through the default lists
+
+	return txApp.Save(record)
+}
+
+// submissionDetails is what a moderator is asked to review, either a new entry or a pending improvement
+type submissionDetails struct {
+	Name            string
+	Score           int
+	LevelsCompleted int
+	CompletionTime  int
+	Submitted       time.Time
+	Flags           []string
+	IsImprovement   bool
+	PublishedScore  int
+}
+
+func submissionDetailsFor(record *core.Record) submissionDetails {
+	if hasPendingImprovement(record) {
+		details := submissionDetails{
+			Name:            record.GetString("pending_name"),
+			Score:           record.GetInt("pending_score"),
+			LevelsCompleted: record.GetInt("pending_levels_completed"),
+			CompletionTime:  record.GetInt("pending_completion_time"),
+			Submitted:       record.GetDateTime("pending_submitted").Time(),
+			IsImprovement:   true,
+			PublishedScore:  record.GetInt("score"),
+		}
+		_ = record.UnmarshalJSONField("pending_flags", &details.Flags)
+		return details
+	}
+
+	details := submissionDetails{
+		Name:            record.GetString("name"),
+		Score:           record.GetInt("score"),
+		LevelsCompleted: record.GetInt("levels_completed"),
+		CompletionTime:  record.GetInt("completion_time"),
+		Submitted:       record.GetDateTime("created").Time(),
+	}
+	_ = record.UnmarshalJSONField("flags", &details.Flags)
+	return details
+}
+
+// CompletionSeconds is the completion time as shown to moderators
+func (d submissionDetails) CompletionSeconds() int {
+	return d.CompletionTime / 1000
+}
+
+// Pending improvements live alongside the published score so it stays visible while they wait for
+// moderation. Only an approved entry has one, an unapproved entry is reviewed as it stands.
+func hasPendingImprovement(record *core.Record) bool {
+	return record.GetBool("approved") && !record.GetDateTime("pending_submitted").IsZero()
+}
+
+// bestKnownScore is the score a new submission has to beat, counting any pending improvement
+func bestKnownScore(record *core.Record) int {
+	if hasPendingImprovement(record) && record.GetInt("pending_score") > record.GetInt("score") {
+		return record.GetInt("pending_score")
+	}
+
+	return record.GetInt("score")
+}
+
+func applyPendingImprovement(record *core.Record) {
+	record.Set("name", record.GetString("pending_name"))
+	record.Set("name_locales", record.GetString("pending_name_locales"))
+	record.Set("score", record.GetInt("pending_score"))
+	record.Set("levels_completed", record.GetInt("pending_levels_completed"))
+	record.Set("completion_time", record.GetInt("pending_completion_time"))
+	// The published flags were about the old score
+	flags := []string{}
+	_ = record.UnmarshalJSONField("pending_flags", &flags)
+	record.Set("flags", flags)
+	// Empty unless a moderator renamed the improvement, the published name is the player's own again
+	record.Set("renamed_from", record.GetString("pending_renamed_from"))
+	clearPendingImprovement(record)
+}
+
+func clearPendingImprovement(record *core.Record) {
+	record.Set("pending_name", "")
+	record.Set("pending_name_locales", "")
+	record.Set("pending_score", 0)
+	record.Set("pending_levels_completed", 0)
+	record.Set("pending_completion_time", 0)
+	record.Set("pending_flags", []string{})
+	record.Set("pending_renamed_from", "")
+	record.Set("pending_submitted", "")
+}
+
 func (h *Handlers) getTotalPlayerCount() (int, error) {
 	allRecords, err := h.app.CountRecords(
 		"leaderboard",
-		&dbx.HashExp{"approved": true},
+		&dbx.HashExp{"approved": true, "shadowed": false, "deleted_at": ""},
 	)
 	if err != nil {
 		return 0, err
@@ -518,9 +779,15 @@ func (h *Handlers) getSigningKey() string {
 	return key
 }
 
-func (h *Handlers) generateSignature(action, id string) string {
+// The moderator is the recipient the link was sent to, links without one still verify for older emails.
+// Mail servers and clients don't keep the case of an address, so it's signed in lower case.
+func (h *Handlers) generateSignature(action, id, moderator string) string {
 	key := h.getSigningKey()
+	moderator = strings.ToLower(strings.TrimSpace(moderator))
 	message := fmt.Sprintf("%s:%s", action, id)
+	if moderator != "" {
+		message = fmt.Sprintf("%s:%s:%s", action, id, moderator)
+	}
 
 	if key == "" {
 		// i'd rather call no key a bad signature rather than pretend all is well
@@ -532,12 +799,12 @@ func (h *Handlers) generateSignature(action, id string) string {
 	return hex.EncodeToString(mac.Sum(nil))
 }
 
-func (h *Handlers) verifySignature(action, id, signature string) bool {
+func (h *Handlers) verifySignature(action, id, moderator, signature string) bool {
 	// blank signature is never valid
 	if signature == "" {
 		return false
 	}
 
-	expected := h.generateSignature(action, id)
+	expected := h.generateSignature(action, id, moderator)
 	return hmac.Equal([]byte(expected), []byte(signature))
 }
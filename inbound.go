@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Each moderation email carries a thread token, "mod.<entry id>.<signature>", in its Message-ID
+// and (when INBOUND_MAIL_ADDRESS is set) in a plus-addressed Reply-To. The signature covers the
+// recipient, so a reply only counts when it comes back from the superuser the email was sent to.
+var threadTokenPattern = regexp.MustCompile(`mod\.([a-z0-9]{15})\.([a-f0-9]{64})`)
+
+const maxInboundMailSize = 1 << 20
+
+type inboundMailResult struct {
+	Action  string `json:"action"`
+	EntryID string `json:"entryId"`
+	Message string `json:"message"`
+}
+
+func threadToken(id, email string, signer SignatureGenerator) string {
+	return fmt.Sprintf("mod.%s.%s", id, signer.generateSignature("reply", id, email))
+}
+
+// replyHeaders threads a moderation email so that replying to it can moderate the entry
+func replyHeaders(app core.App, id, email string, signer SignatureGenerator) map[string]string {
+	token := threadToken(id, email, signer)
+
+	host := "localhost"
+	if parsed, err := url.Parse(appBaseURL(app)); err == nil && parsed.Hostname() != "" {
+		host = parsed.Hostname()
+	}
+
+	headers := map[string]string{
+		"Message-ID": fmt.Sprintf("<%s@%s>", token, host),
+	}
+
+	if address := os.Getenv("INBOUND_MAIL_ADDRESS"); address != "" {
+		if local, domain, ok := strings.Cut(address, "@"); ok {
+			headers["Reply-To"] = fmt.Sprintf("%s+%s@%s", local, token, domain)
+		}
+	}
+
+	return headers
+}
+
+// inboundMailAuthorized accepts a superuser session or the shared INBOUND_MAIL_TOKEN,
+// which is what an MTA pipe or mail provider webhook would be configured with
+func inboundMailAuthorized(e *core.RequestEvent) bool {
+	if e.HasSuperuserAuth() {
+		return true
+	}
+
+	token := os.Getenv("INBOUND_MAIL_TOKEN")
+	if token == "" {
+		return false
+	}
+
+	given := strings.TrimPrefix(e.Request.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// receiveMail takes a raw RFC 5322 reply to a moderation email and applies the command in its
+// first line: APPROVE [reason], DELETE [reason] or RENAME <name>
+func (h *Handlers) receiveMail(e *core.RequestEvent) error {
+	if !inboundMailAuthorized(e) {
+		return e.UnauthorizedError("Invalid inbound mail token", nil)
+	}
+
+	msg, err := mail.ReadMessage(io.LimitReader(e.Request.Body, maxInboundMailSize))
+	if err != nil {
+		return e.BadRequestError("Could not parse message", err)
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return e.BadRequestError("Missing or invalid From address", err)
+	}
+
+	if _, err := h.app.FindAuthRecordByEmail(core.CollectionNameSuperusers, from.Address); err != nil {
+		return e.ForbiddenError("Sender is not a superuser", nil)
+	}
+
+	id, ok := h.findThreadEntry(msg.Header, from.Address)
+	if !ok {
+		metrics.signatureFailures.inc("reply")
+		return e.ForbiddenError("Message does not answer a moderation email", nil)
+	}
+
+	body, err := plainTextBody(msg.Header, msg.Body)
+	if err != nil {
+		return e.BadRequestError("Could not read message body", err)
+	}
+
+	command, argument := replyCommand(body)
+
+	record, err := h.findActiveEntry(id)
+	if err != nil {
+		return e.NotFoundError("Score not found", err)
+	}
+
+	result := inboundMailResult{Action: strings.ToLower(command), EntryID: id}
+
+	switch command {
+	case "APPROVE":
+		err = h.app.RunInTransaction(func(txApp core.App) error {
+			return approveEntry(txApp, record, from.Address, argument)
+		})
+		result.Message = "Score approved"
+	case "DELETE":
+		var discarded bool
+		err = h.app.RunInTransaction(func(txApp core.App) error {
+			discarded, err = deleteEntry(txApp, record, from.Address, argument)
+			return err
+		})
+		result.Message = "Score deleted"
+		if discarded {
+			result.Message = "Pending improvement discarded"
+		}
+	case "RENAME":
+		name, nameErr := h.moderatorName(argument)
+		if nameErr != nil {
+			return e.BadRequestError(fmt.Sprintf("RENAME needs a new name of at least %d characters that get through the filter", minNameGraphemes), nil)
+		}
+
+		err = h.app.RunInTransaction(func(txApp core.App) error {
+			return renameEntry(txApp, record, name, from.Address, "renamed by email reply to "+name)
+		})
+		result.Message = "Renamed to " + name
+	default:
+		return e.BadRequestError("Reply must start with APPROVE, DELETE or RENAME <name>", nil)
+	}
+
+	if err != nil {
+		return e.InternalServerError("Failed to apply "+result.Action, err)
+	}
+
+	return e.JSON(http.StatusOK, result)
+}
+
+// findThreadEntry looks for a thread token signed for the sender in the headers a reply carries
+func (h *Handlers) findThreadEntry(header mail.Header, sender string) (string, bool) {
+	for _, key := range []string{"In-Reply-To", "References", "To", "Delivered-To"} {
+		for _, match := range threadTokenPattern.FindAllStringSubmatch(header.Get(key), -1) {
+			if h.verifySignature("reply", match[1], sender, match[2]) {
+				return match[1], true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// replyCommand finds the first line the moderator wrote, skipping blanks and quoted text
+func replyCommand(body string) (command, argument string) {
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ">") {
+			continue
+		}
+
+		command, argument, _ = strings.Cut(line, " ")
+		return strings.ToUpper(command), strings.TrimSpace(argument)
+	}
+
+	return "", ""
+}
+
+// plainTextBody returns the first text/plain part, decoding multipart and transfer encodings
+func plainTextBody(header textprotoHeader, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return "", nil
+			}
+			if err != nil {
+				return "", err
+			}
+
+			// NextPart already undoes quoted-printable, but not base64
+			text, err := plainTextBody(part.Header, part)
+			if err != nil {
+				return "", err
+			}
+			if text != "" {
+				return text, nil
+			}
+		}
+	}
+
+	if mediaType != "text/plain" {
+		return "", nil
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// textprotoHeader is satisfied by both mail.Header and a multipart part's header
+type textprotoHeader interface {
+	Get(key string) string
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Built-in mail templates. Any of them can be replaced by dropping a file with the
+// same name into MAIL_TEMPLATES_DIR, which is read on every send so edits apply immediately.
+//
+//go:embed mailtemplates
+var mailTemplatesFS embed.FS
+
+var mailTemplateFuncs = map[string]any{
+	"join": strings.Join,
+	"inc":  func(i int) int { return i + 1 },
+}
+
+type moderationMailData struct {
+	ModerationNotice
+	Subject    string
+	Action     string
+	ApproveURL string
+	DeleteURL  string
+}
+
+type digestMailEntry struct {
+	ModerationNotice
+	ApproveURL string
+	DeleteURL  string
+}
+
+type digestMailData struct {
+	Subject       string
+	Entries       []digestMailEntry
+	ApproveAllURL string
+}
+
+func mailTemplateSource(name string) (string, error) {
+	if dir := os.Getenv("MAIL_TEMPLATES_DIR"); dir != "" {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(content), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	content, err := mailTemplatesFS.ReadFile("mailtemplates/" + name)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// renderMail renders both parts of a multipart email from <name>.txt and <name>.html
+func renderMail(name string, data any) (text string, html string, err error) {
+	textSource, err := mailTemplateSource(name + ".txt")
+	if err != nil {
+		return "", "", err
+	}
+
+	textTmpl, err := texttemplate.New(name + ".txt").Funcs(mailTemplateFuncs).Parse(textSource)
+	if err != nil {
+		return "", "", err
+	}
+
+	var textBuf bytes.Buffer
+	if err := textTmpl.Execute(&textBuf, data); err != nil {
+		return "", "", err
+	}
+
+	htmlSource, err := mailTemplateSource(name + ".html")
+	if err != nil {
+		return "", "", err
+	}
+
+	htmlTmpl, err := htmltemplate.New(name + ".html").Funcs(mailTemplateFuncs).Parse(htmlSource)
+	if err != nil {
+		return "", "", err
+	}
+
+	var htmlBuf bytes.Buffer
+	if err := htmlTmpl.Execute(&htmlBuf, data); err != nil {
+		return "", "", err
+	}
+
+	return textBuf.String(), htmlBuf.String(), nil
+}
+
+// previewModerationMail renders the moderation email for an entry exactly as the calling
+// superuser would receive it. Add ?format=text for the plain text part.
+func (h *Handlers) previewModerationMail(e *core.RequestEvent) error {
+	record, err := h.app.FindRecordById("leaderboard", e.Request.PathValue("id"))
+	if err != nil {
+		return e.NotFoundError("Score not found", err)
+	}
+
+	// The reserved-name collision isn't stored on the entry, so check it again as the submission did
+	notice := noticeFromRecord(record)
+	notice.ReservedName, err = h.findReservedName(notice.Name, record.GetString("identifier"))
+	if err != nil {
+		return e.InternalServerError("Failed to check reserved names", err)
+	}
+
+	_, text, html, err := h.emailService.renderModerationEmail(notice, e.Auth.Email(), h)
+	if err != nil {
+		return e.InternalServerError("Failed to render email", err)
+	}
+
+	if e.Request.URL.Query().Get("format") == "text" {
+		return e.String(http.StatusOK, text)
+	}
+
+	allowInlineStyleAttributes(e)
+	return e.HTML(http.StatusOK, html)
+}
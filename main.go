@@ -12,6 +12,7 @@ import (
 
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/plugins/migratecmd"
 )
@@ -30,36 +31,21 @@ func main() {
 		Automigrate: isGoRun,
 	})
 
-	// Configure CORS and static file serving
-	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
-		// Add CORS middleware
-		se.Router.BindFunc(func(e *core.RequestEvent) error {
-			// Allow requests from localhost during development
-			allowedOrigins := []string{
-				"http://localhost:3000",
-				"http://localhost:5173",
-				"http://localhost:8090",
-			}
-
-			origin := e.Request.Header.Get("Origin")
-			for _, allowedOrigin := range allowedOrigins {
-				if origin == allowedOrigin {
-					e.Response.Header().Set("Access-Control-Allow-Origin", origin)
-					break
-				}
-			}
+	// Moderation from the command line: leaderboard pending, approve, delete, top, show, sign-url, export, import, seed, revalidate
+	registerLeaderboardCommands(app)
 
-			e.Response.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			e.Response.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			e.Response.Header().Set("Access-Control-Max-Age", "86400")
+	// Write outgoing mail to disk instead of sending it, with a viewer at /_dev/mail
+	if mailerMode(isGoRun) == mailerModeSpool {
+		registerMailSpool(app)
+	}
 
-			// Handle preflight requests
-			if e.Request.Method == "OPTIONS" {
-				return e.NoContent(204)
-			}
+	// Configure CORS, see cors.go for the settings
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.Unbind(apis.DefaultCorsMiddlewareId)
+		se.Router.Bind(corsMiddleware(serveOrigins(app)))
 
-			return e.Next()
-		})
+		// Security headers and CSP, see security.go for the settings
+		se.Router.Bind(securityHeadersMiddleware())
 
 		return se.Next()
 	})
@@ -100,6 +86,9 @@ func main() {
 		// Setup API routes
 		h := NewHandlers(app)
 		h.RegisterRoutes(se)
+		h.RegisterCronJobs()
+		h.RegisterWordLists()
+		h.RegisterMetrics()
 
 		return se.Next()
 	})
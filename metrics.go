@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// Metrics are served at /metrics in the Prometheus text format. With METRICS_TOKEN set a scrape
+// needs "Authorization: Bearer <token>", without it only direct connections from loopback get
+// in. Behind a reverse proxy on the same machine everything looks like loopback, so set a token
+// there (proxied requests without one are turned away by their X-Forwarded-For header).
+
+// Submission outcomes
+const (
+	submissionNew      = "new"
+	submissionImproved = "improved"
+	submissionNotBest  = "not_best"
+	submissionInvalid  = "invalid"
+)
+
+// Notification results
+const (
+	notificationSuccess = "success"
+	notificationFailure = "failure"
+)
+
+// Latency buckets in seconds
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// counter is a counter family, one series per combination of label values
+type counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]float64
+}
+
+func newCounter(name, help string, labels ...string) *counter {
+	return &counter{name: name, help: help, labels: labels, series: map[string]float64{}}
+}
+
+// inc counts one, with the label values in the order the labels were declared
+func (c *counter) inc(values ...string) {
+	c.mu.Lock()
+	c.series[formatLabels(c.labels, values)]++
+	c.mu.Unlock()
+}
+
+func (c *counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, labels := range sortedKeys(c.series) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labels, formatValue(c.series[labels]))
+	}
+}
+
+type histogramSeries struct {
+	buckets []uint64 // cumulative counts are worked out when writing
+	sum     float64
+	count   uint64
+}
+
+// histogram is a histogram family with a single label
+type histogram struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+func newHistogram(name, help, label string, buckets []float64) *histogram {
+	return &histogram{name: name, help: help, label: label, buckets: buckets, series: map[string]*histogramSeries{}}
+}
+
+func (h *histogram) observe(value string, v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	series, ok := h.series[value]
+	if !ok {
+		series = &histogramSeries{buckets: make([]uint64, len(h.buckets))}
+		h.series[value] = series
+	}
+
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		series.buckets[i]++
+	}
+	series.sum += v
+	series.count++
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, value := range sortedKeys(h.series) {
+		series := h.series[value]
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += series.buckets[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name,
+				formatLabels([]string{h.label, "le"}, []string{value, formatValue(bound)}), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels([]string{h.label, "le"}, []string{value, "+Inf"}), series.count)
+
+		labels := formatLabels([]string{h.label}, []string{value})
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, series.count)
+	}
+}
+
+func writeGauge(w io.Writer, name, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatValue(value))
+}
+
+// formatLabels renders {name="value",...}, escaping the values as the text format wants
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, escape.Replace(value))
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// Everything the app counts. The gauges are read from the database on each scrape instead.
+var metrics = struct {
+	submissions       *counter
+	moderationActions *counter
+	notifications     *counter
+	signatureFailures *counter
+	requestLatency    *histogram
+}{
+	submissions: newCounter("leaderboard_submissions_total",
+		"Score submissions by outcome.", "outcome"),
+	moderationActions: newCounter("leaderboard_moderation_actions_total",
+		"Moderation actions written to the moderation log.", "action"),
+	notifications: newCounter("leaderboard_notifications_total",
+		"Moderation notification deliveries by channel and result.", "channel", "result"),
+	signatureFailures: newCounter("leaderboard_signature_failures_total",
+		"Signed links, forms and replies that failed verification.", "action"),
+	requestLatency: newHistogram("leaderboard_request_duration_seconds",
+		"Time taken to answer leaderboard requests.", "route", latencyBuckets),
+}
+
+// measureLatency times a route's handlers for the request latency histogram
+func measureLatency(route string) *hook.Handler[*core.RequestEvent] {
+	return &hook.Handler[*core.RequestEvent]{
+		Func: func(e *core.RequestEvent) error {
+			start := time.Now()
+			err := e.Next()
+			metrics.requestLatency.observe(route, time.Since(start).Seconds())
+			return err
+		},
+	}
+}
+
+// RegisterMetrics counts moderation actions as they're logged, after the transaction that logs
+// them commits, so the CLI and every handler are covered without each one counting
+func (h *Handlers) RegisterMetrics() {
+	h.app.OnRecordAfterCreateSuccess("moderation_log").BindFunc(func(e *core.RecordEvent) error {
+		metrics.moderationActions.inc(e.Record.GetString("action"))
+		return e.Next()
+	})
+}
+
+// canScrapeMetrics checks the bearer token, or without one that the request came straight from loopback
+func canScrapeMetrics(r *http.Request) bool {
+	if token := os.Getenv("METRICS_TOKEN"); token != "" {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+	}
+
+	return isLoopbackRequest(r)
+}
+
+// isLoopbackRequest is true for a request made on this machine, not one relayed by a proxy
+func isLoopbackRequest(r *http.Request) bool {
+	if r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("X-Real-IP") != "" {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+func (h *Handlers) serveMetrics(e *core.RequestEvent) error {
+	if !canScrapeMetrics(e.Request) {
+		return e.ForbiddenError("Metrics are only available with a token or from loopback", nil)
+	}
+
+	pending, err := h.app.CountRecords(
+		"leaderboard",
+		dbx.HashExp{"deleted_at": ""},
+		dbx.Or(dbx.HashExp{"approved": false}, dbx.NewExp("pending_submitted != ''")),
+	)
+	if err != nil {
+		return e.InternalServerError("Failed to count pending entries", err)
+	}
+
+	approved, err := h.getTotalPlayerCount()
+	if err != nil {
+		return e.InternalServerError("Failed to count approved players", err)
+	}
+
+	var buf bytes.Buffer
+	metrics.submissions.write(&buf)
+	metrics.moderationActions.write(&buf)
+	metrics.notifications.write(&buf)
+	metrics.signatureFailures.write(&buf)
+	metrics.requestLatency.write(&buf)
+	writeGauge(&buf, "leaderboard_pending_entries", "Entries waiting for a moderator, new or improved.", float64(pending))
+	writeGauge(&buf, "leaderboard_approved_players", "Players on the public leaderboard.", float64(approved))
+
+	return e.Blob(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,120 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3906957607")
+		if err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(7, []byte(`{
+			"autogeneratePattern": "",
+			"hidden": true,
+			"id": "text2906127734",
+			"max": 20,
+			"min": 0,
+			"name": "pending_name",
+			"pattern": "",
+			"presentable": false,
+			"primaryKey": false,
+			"required": false,
+			"system": false,
+			"type": "text"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(8, []byte(`{
+			"hidden": true,
+			"id": "number1468127306",
+			"max": null,
+			"min": null,
+			"name": "pending_score",
+			"onlyInt": true,
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "number"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(9, []byte(`{
+			"hidden": true,
+			"id": "number3040127711",
+			"max": null,
+			"min": null,
+			"name": "pending_levels_completed",
+			"onlyInt": true,
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "number"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(10, []byte(`{
+			"hidden": true,
+			"id": "number1117246380",
+			"max": null,
+			"min": null,
+			"name": "pending_completion_time",
+			"onlyInt": false,
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "number"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(11, []byte(`{
+			"hidden": true,
+			"id": "date2413651202",
+			"max": "",
+			"min": "",
+			"name": "pending_submitted",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "date"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3906957607")
+		if err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("text2906127734")
+
+		// remove field
+		collection.Fields.RemoveById("number1468127306")
+
+		// remove field
+		collection.Fields.RemoveById("number3040127711")
+
+		// remove field
+		collection.Fields.RemoveById("number1117246380")
+
+		// remove field
+		collection.Fields.RemoveById("date2413651202")
+
+		return app.Save(collection)
+	})
+}
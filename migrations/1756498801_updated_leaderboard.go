@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3906957607")
+		if err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(12, []byte(`{
+			"hidden": true,
+			"id": "json1874629670",
+			"maxSize": 0,
+			"name": "flags",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "json"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(13, []byte(`{
+			"cascadeDelete": false,
+			"collectionId": "pbc_1483216975",
+			"hidden": true,
+			"id": "relation3366927519",
+			"maxSelect": 1,
+			"minSelect": 0,
+			"name": "approval_rule",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "relation"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3906957607")
+		if err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("json1874629670")
+
+		// remove field
+		collection.Fields.RemoveById("relation3366927519")
+
+		return app.Save(collection)
+	})
+}
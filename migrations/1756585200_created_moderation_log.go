@@ -0,0 +1,160 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "select1204587666",
+					"maxSelect": 1,
+					"name": "action",
+					"presentable": true,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"approve",
+						"auto_approve",
+						"delete",
+						"discard_improvement"
+					]
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1762151306",
+					"max": 15,
+					"min": 0,
+					"name": "entry_id",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1999537002",
+					"max": 50,
+					"min": 0,
+					"name": "identifier",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "json2585640934",
+					"maxSize": 0,
+					"name": "entry",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "json"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2446162004",
+					"max": 255,
+					"min": 0,
+					"name": "moderator",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1001949196",
+					"max": 500,
+					"min": 0,
+					"name": "reason",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_2860371349",
+			"indexes": [
+				"CREATE INDEX ` + "`" + `idx_modlog_identifier` + "`" + ` ON ` + "`" + `moderation_log` + "`" + ` (` + "`" + `identifier` + "`" + `)",
+				"CREATE INDEX ` + "`" + `idx_modlog_entry_id` + "`" + ` ON ` + "`" + `moderation_log` + "`" + ` (` + "`" + `entry_id` + "`" + `)"
+			],
+			"listRule": null,
+			"name": "moderation_log",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": null
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2860371349")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,144 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "pbc_3142635823",
+					"hidden": false,
+					"id": "relation3128393596",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "superuser",
+					"presentable": true,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"hidden": false,
+					"id": "select2239752261",
+					"maxSelect": 1,
+					"name": "delivery",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"immediate",
+						"digest",
+						"both",
+						"none"
+					]
+				},
+				{
+					"hidden": false,
+					"id": "number2717328925",
+					"max": 23,
+					"min": 0,
+					"name": "quiet_start",
+					"onlyInt": true,
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "number"
+				},
+				{
+					"hidden": false,
+					"id": "number1520372934",
+					"max": 23,
+					"min": 0,
+					"name": "quiet_end",
+					"onlyInt": true,
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "number"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text3367473359",
+					"max": 64,
+					"min": 0,
+					"name": "timezone",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_2410893675",
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_modprefs_superuser` + "`" + ` ON ` + "`" + `moderator_preferences` + "`" + ` (` + "`" + `superuser` + "`" + `)"
+			],
+			"listRule": null,
+			"name": "moderator_preferences",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": null
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2410893675")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
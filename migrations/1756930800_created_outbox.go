@@ -0,0 +1,164 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1930317162",
+					"max": 50,
+					"min": 0,
+					"name": "channel",
+					"pattern": "",
+					"presentable": true,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "json1110206997",
+					"maxSize": 0,
+					"name": "payload",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "json"
+				},
+				{
+					"hidden": false,
+					"id": "select2063623452",
+					"maxSelect": 1,
+					"name": "status",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"pending",
+						"sent",
+						"failed"
+					]
+				},
+				{
+					"hidden": false,
+					"id": "number2226069447",
+					"max": null,
+					"min": 0,
+					"name": "attempts",
+					"onlyInt": true,
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "number"
+				},
+				{
+					"hidden": false,
+					"id": "date2767474963",
+					"max": "",
+					"min": "",
+					"name": "next_attempt",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "date"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text3110452339",
+					"max": 2000,
+					"min": 0,
+					"name": "last_error",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "date1489565282",
+					"max": "",
+					"min": "",
+					"name": "sent_at",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "date"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_3394071216",
+			"indexes": [
+				"CREATE INDEX ` + "`" + `idx_outbox_due` + "`" + ` ON ` + "`" + `outbox` + "`" + ` (` + "`" + `status` + "`" + `, ` + "`" + `next_attempt` + "`" + `)"
+			],
+			"listRule": null,
+			"name": "outbox",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": null
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3394071216")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
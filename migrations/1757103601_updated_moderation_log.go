@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2860371349")
+		if err != nil {
+			return err
+		}
+
+		// update field
+		if err := collection.Fields.AddMarshaledJSONAt(1, []byte(`{
+			"hidden": false,
+			"id": "select1204587666",
+			"maxSelect": 1,
+			"name": "action",
+			"presentable": true,
+			"required": true,
+			"system": false,
+			"type": "select",
+			"values": [
+				"approve",
+				"auto_approve",
+				"delete",
+				"discard_improvement",
+				"rename",
+				"restore"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2860371349")
+		if err != nil {
+			return err
+		}
+
+		// update field
+		if err := collection.Fields.AddMarshaledJSONAt(1, []byte(`{
+			"hidden": false,
+			"id": "select1204587666",
+			"maxSelect": 1,
+			"name": "action",
+			"presentable": true,
+			"required": true,
+			"system": false,
+			"type": "select",
+			"values": [
+				"approve",
+				"auto_approve",
+				"delete",
+				"discard_improvement",
+				"rename"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,114 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1570961127",
+					"max": 255,
+					"min": 0,
+					"name": "recipient",
+					"pattern": "",
+					"presentable": true,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "date1326724116",
+					"max": "",
+					"min": "",
+					"name": "issued",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "date"
+				},
+				{
+					"hidden": false,
+					"id": "number3417186117",
+					"max": null,
+					"min": 0,
+					"name": "entries",
+					"onlyInt": true,
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "number"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_1548309241",
+			"indexes": [
+				"CREATE INDEX ` + "`" + `idx_moderation_digests_recipient` + "`" + ` ON ` + "`" + `moderation_digests` + "`" + ` (` + "`" + `recipient` + "`" + `, ` + "`" + `issued` + "`" + `)"
+			],
+			"listRule": null,
+			"name": "moderation_digests",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": null
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_1548309241")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
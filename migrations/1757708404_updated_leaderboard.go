@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3906957607")
+		if err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(19, []byte(`{
+			"hidden": true,
+			"id": "json2104732881",
+			"maxSize": 0,
+			"name": "pending_flags",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "json"
+		}`)); err != nil {
+			return err
+		}
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		// Until now a pending improvement's flags replaced the published ones, so they're the pending ones
+		_, err = app.DB().NewQuery("UPDATE leaderboard SET pending_flags = flags WHERE pending_submitted != ''").Execute()
+		return err
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3906957607")
+		if err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("json2104732881")
+
+		return app.Save(collection)
+	})
+}
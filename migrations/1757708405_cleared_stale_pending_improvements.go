@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// An improvement parked on an entry that was un-approved later was never shown to a moderator,
+		// approving the entry mustn't publish it
+		_, err := app.DB().NewQuery(`UPDATE leaderboard SET
+			pending_name = '', pending_name_locales = '', pending_score = 0, pending_levels_completed = 0,
+			pending_completion_time = 0, pending_flags = '[]', pending_submitted = ''
+			WHERE approved = false AND pending_submitted != ''`).Execute()
+		return err
+	}, nil)
+}
@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3906957607")
+		if err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(20, []byte(`{
+			"autogeneratePattern": "",
+			"hidden": true,
+			"id": "text2763413526",
+			"max": 100,
+			"min": 0,
+			"name": "pending_renamed_from",
+			"pattern": "",
+			"presentable": false,
+			"primaryKey": false,
+			"required": false,
+			"system": false,
+			"type": "text"
+		}`)); err != nil {
+			return err
+		}
+
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		// An improvement used to clear renamed_from when it was submitted, so one set since then was
+		// a moderator renaming the improvement
+		_, err = app.DB().NewQuery(`UPDATE leaderboard SET pending_renamed_from = renamed_from, renamed_from = ''
+			WHERE pending_submitted != '' AND renamed_from != ''`).Execute()
+		return err
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3906957607")
+		if err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("text2763413526")
+
+		return app.Save(collection)
+	})
+}
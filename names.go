@@ -0,0 +1,227 @@
+package main
+
+import (
+	"cookie-banner-clicker/filter"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Names are limited in grapheme clusters, what a player sees as one character,
+// so "Zoë" is 3 long however it was typed and an emoji flag counts once
+const (
+	minNameGraphemes = 2
+	maxNameGraphemes = 20
+)
+
+// A cluster can soak up any number of combining marks, so a short name can still be far longer
+// than it looks. Letters keep at most maxClusterMarks of them, and a name longer than maxNameRunes
+// (long emoji sequences add up fast) is refused rather than overflowing the 100 character field.
+const (
+	maxClusterMarks = 3
+	maxNameRunes    = 80
+)
+
+// What happens to emoji in names, set with NAME_EMOJI
+const (
+	emojiAllow  = "allow"
+	emojiStrip  = "strip"
+	emojiReject = "reject"
+)
+
+func nameEmojiPolicy() string {
+	switch policy := os.Getenv("NAME_EMOJI"); policy {
+	case emojiAllow, emojiReject:
+		return policy
+	default:
+		return emojiStrip
+	}
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // pictographs, emoticons, transport, flags, supplemental symbols
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // misc symbols and dingbats
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF, r >= 0x2300 && r <= 0x23FF:
+		return true
+	case r == 0x00A9, r == 0x00AE, r == 0x203C, r == 0x2049, r == 0x2122, r == 0x2139:
+		return true
+	}
+
+	return false
+}
+
+// extendsCluster is anything that attaches to the character before it instead of standing alone
+func extendsCluster(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == 0x200D || // zero width joiner, glues emoji sequences together
+		(r >= 0xFE00 && r <= 0xFE0F) || // variation selectors
+		(r >= 0x1F3FB && r <= 0x1F3FF) || // skin tone modifiers
+		(r >= 0xE0020 && r <= 0xE007F) || // tag characters used by subdivision flags
+		r == 0x20E3 // combining keycap
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// graphemes splits text into user-perceived characters. It follows the parts of Unicode's
+// grapheme rules that matter for names (combining marks, emoji sequences, flag pairs)
+// rather than the whole of UAX #29.
+func graphemes(text string) []string {
+	var clusters []string
+	var current []rune
+	joinNext := false
+	regionalCount := 0
+
+	for _, r := range text {
+		attach := len(current) > 0 && (extendsCluster(r) || joinNext ||
+			(isRegionalIndicator(r) && regionalCount%2 == 1))
+
+		if !attach && len(current) > 0 {
+			clusters = append(clusters, string(current))
+			current = nil
+		}
+		current = append(current, r)
+
+		joinNext = r == 0x200D
+		if isRegionalIndicator(r) {
+			regionalCount++
+		} else if !extendsCluster(r) {
+			regionalCount = 0
+		}
+	}
+
+	if len(current) > 0 {
+		clusters = append(clusters, string(current))
+	}
+
+	return clusters
+}
+
+// stripInvisible removes characters that can't be seen or that reorder text: bidi controls,
+// zero-width spaces and joiners, and other format characters. Joiners and variation
+// selectors are kept only inside emoji, the one place they're needed.
+func stripInvisible(text string) string {
+	var b strings.Builder
+	var prev rune
+	for _, r := range text {
+		keep := true
+		switch {
+		case r == 0x200D || (r >= 0xFE00 && r <= 0xFE0F):
+			keep = isEmoji(prev) || (prev >= 0x1F3FB && prev <= 0x1F3FF)
+		case unicode.Is(unicode.Cf, r) && !(r >= 0xE0020 && r <= 0xE007F):
+			keep = false
+		case unicode.IsControl(r):
+			keep = false
+		}
+
+		if keep {
+			b.WriteRune(r)
+			prev = r
+		}
+	}
+
+	return b.String()
+}
+
+// cleanNameChars keeps letters, digits, spaces and the filter's asterisks, handling emoji
+// clusters according to the policy. hadEmoji reports whether there were any.
+func cleanNameChars(text string, policy string) (cleaned string, hadEmoji bool) {
+	var b strings.Builder
+	lastSpace := true
+
+	for _, cluster := range graphemes(text) {
+		first := []rune(cluster)[0]
+
+		if isEmoji(first) || isRegionalIndicator(first) {
+			hadEmoji = true
+			if policy == emojiAllow {
+				b.WriteString(cluster)
+				lastSpace = false
+			}
+			continue
+		}
+
+		if unicode.IsSpace(first) {
+			if !lastSpace {
+				b.WriteRune(' ')
+			}
+			lastSpace = true
+			continue
+		}
+
+		if unicode.IsLetter(first) || unicode.IsDigit(first) || first == '*' {
+			b.WriteString(capClusterMarks(cluster))
+			lastSpace = false
+		}
+	}
+
+	return strings.TrimSpace(b.String()), hadEmoji
+}
+
+// capClusterMarks drops combining marks past maxClusterMarks, which is enough for any real
+// letter but not for the stacks of accents in "zalgo" text
+func capClusterMarks(cluster string) string {
+	var b strings.Builder
+	marks := 0
+	for _, r := range cluster {
+		if unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) {
+			if marks++; marks > maxClusterMarks {
+				continue
+			}
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// truncateGraphemes cuts text to at most n user-perceived characters
+func truncateGraphemes(text string, n int) string {
+	clusters := graphemes(text)
+	if len(clusters) <= n {
+		return text
+	}
+
+	return strings.Join(clusters[:n], "")
+}
+
+// Scripts that share look-alike letters with each other, which is what spoofing relies on
+var confusableScripts = []*unicode.RangeTable{unicode.Latin, unicode.Cyrillic, unicode.Greek}
+
+// hasMixedScriptWord reports whether any single word mixes Latin, Cyrillic and Greek letters,
+// as in "Pаul" with a Cyrillic "а". Different words in different scripts are fine.
+func hasMixedScriptWord(name string) bool {
+	for _, word := range strings.Fields(name) {
+		var seen *unicode.RangeTable
+		for _, r := range word {
+			for _, script := range confusableScripts {
+				if !unicode.Is(script, r) {
+					continue
+				}
+				if seen != nil && seen != script {
+					return true
+				}
+				seen = script
+			}
+		}
+	}
+
+	return false
+}
+
+// nameSkeleton is what a name looks like once look-alike characters, accents, case and
+// spacing are folded away. Two different names with the same skeleton are confusable.
+func nameSkeleton(name string) string {
+	return filter.Normalize(strings.Join(strings.Fields(name), ""))
+}
+
+// normalizeName puts a name in NFC and drops invisible characters, the first step of sanitizing it
+func normalizeName(name string) string {
+	return strings.TrimSpace(stripInvisible(norm.NFC.String(name)))
+}
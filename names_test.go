@@ -0,0 +1,39 @@
+package main
+
+import (
+	"cookie-banner-clicker/filter"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeNameFitsTheNameField(t *testing.T) {
+	h := &Handlers{nameFilter: filter.New("en")}
+
+	tests := []struct {
+		name  string
+		emoji string
+		input string
+		want  string
+	}{
+		{"accents kept", emojiStrip, "Zoë", "Zoë"},
+		{"stacked marks capped", emojiStrip, "Ab" + strings.Repeat("\u0301", 150), "Ab\u0301\u0301\u0301"},
+		{"zalgo capped on every letter", emojiStrip, strings.Repeat("Z\u0300\u0301\u0302\u0303\u0304", 20), strings.Repeat("Z\u0300\u0301\u0302", 20)},
+		{"short emoji sequence", emojiAllow, "Fam 👨\u200d👩\u200d👧\u200d👦", "Fam 👨\u200d👩\u200d👧\u200d👦"},
+		{"long emoji sequences refused", emojiAllow, strings.Repeat("👨\u200d👩\u200d👧\u200d👦", 20), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NAME_EMOJI", tt.emoji)
+
+			got := h.sanitizeName(tt.input)
+			if got != tt.want {
+				t.Errorf("sanitizeName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if n := utf8.RuneCountInString(got); n > maxNameRunes {
+				t.Errorf("sanitizeName(%q) is %d runes long, more than %d", tt.input, n, maxNameRunes)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ModerationNotice is what notifiers are told about a submission waiting for moderation
+type ModerationNotice struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Score           int      `json:"score"`
+	LevelsCompleted int      `json:"levelsCompleted"`
+	CompletionTime  int      `json:"completionTime"`
+	PublishedScore  int      `json:"publishedScore,omitempty"` // set when this improves on an approved score
+	Flags           []string `json:"flags"`
+	ReservedName    string   `json:"reservedName,omitempty"` // the protected name this one collides with
+	IsNew           bool     `json:"isNew"`
+}
+
+func (n ModerationNotice) CompletionSeconds() int {
+	return n.CompletionTime / 1000
+}
+
+// noticeFromRecord describes whatever on the entry is currently waiting for moderation
+func noticeFromRecord(record *core.Record) ModerationNotice {
+	details := submissionDetailsFor(record)
+
+	return ModerationNotice{
+		ID:              record.Id,
+		Name:            details.Name,
+		Score:           details.Score,
+		LevelsCompleted: details.LevelsCompleted,
+		CompletionTime:  details.CompletionTime,
+		PublishedScore:  details.PublishedScore,
+		Flags:           details.Flags,
+		IsNew:           !details.IsImprovement,
+	}
+}
+
+// Notifier delivers moderation notices somewhere a moderator will see them. Channel names the
+// notifier in the outbox so a retry goes back through the same one, and every recipient gets an
+// outbox row of their own so a retry only reaches the ones who didn't get it.
+type Notifier interface {
+	Channel() string
+	Recipients() ([]string, error)
+	Notify(notice ModerationNotice, recipient string, signer SignatureGenerator) error
+}
+
+// errRecipientGone fails a delivery for good, the recipient no longer wants notices on the channel
+var errRecipientGone = errors.New("recipient no longer receives these notifications")
+
+func (e *EmailService) Channel() string {
+	return "email"
+}
+
+// Recipients is every superuser who wants an email per submission
+func (e *EmailService) Recipients() ([]string, error) {
+	recipients, err := e.moderationRecipients()
+	if err != nil {
+		return nil, err
+	}
+
+	var emails []string
+	for _, recipient := range recipients {
+		if recipient.wantsImmediate() {
+			emails = append(emails, recipient.Email)
+		}
+	}
+
+	return emails, nil
+}
+
+// Notify sends the per-submission email, making EmailService a Notifier
+func (e *EmailService) Notify(notice ModerationNotice, recipient string, signer SignatureGenerator) error {
+	return e.SendModerationEmail(notice, recipient, signer)
+}
+
+// newNotifiers builds every configured notifier, email is always on
+func newNotifiers(app core.App, emailService *EmailService) []Notifier {
+	notifiers := []Notifier{emailService}
+
+	if webhook := NewWebhookNotifierFromEnv(app); webhook != nil {
+		notifiers = append(notifiers, webhook)
+	}
+
+	return notifiers
+}
+
+func appBaseURL(app core.App) string {
+	baseURL := app.Settings().Meta.AppURL
+	if baseURL == "" {
+		baseURL = "http://localhost:8080" // Default for development
+	}
+
+	return baseURL
+}
+
+// signedModerationURL builds a moderation link signed for the recipient so the moderation log knows who acted
+func signedModerationURL(app core.App, action, id, moderator string, signer SignatureGenerator) string {
+	signature := signer.generateSignature(action, id, moderator)
+	return fmt.Sprintf("%s/admin/%s/%s/%s?moderator=%s", appBaseURL(app), action, id, signature, url.QueryEscape(moderator))
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Delivery states of an outbox row
+const (
+	outboxPending = "pending"
+	outboxSent    = "sent"
+	outboxFailed  = "failed"
+)
+
+// Retries back off exponentially from outboxBaseDelay, capped at outboxMaxDelay,
+// and give up (leaving the row for a manual resend) after outboxMaxAttempts
+const (
+	outboxBaseDelay   = time.Minute
+	outboxMaxDelay    = 6 * time.Hour
+	outboxMaxAttempts = 8
+)
+
+var errUnknownChannel = errors.New("no notifier configured for this channel")
+
+// deferredError puts a delivery back in the queue until a later time without counting an attempt
+type deferredError struct {
+	until time.Time
+}
+
+func (e *deferredError) Error() string {
+	return "deferred until " + e.until.Format(time.RFC3339)
+}
+
+type OutboxEntry struct {
+	ID          string           `json:"id"`
+	Channel     string           `json:"channel"`
+	Recipient   string           `json:"recipient"`
+	Notice      ModerationNotice `json:"notice"`
+	Status      string           `json:"status"`
+	Attempts    int              `json:"attempts"`
+	NextAttempt string           `json:"nextAttempt,omitempty"`
+	LastError   string           `json:"lastError,omitempty"`
+	SentAt      string           `json:"sentAt,omitempty"`
+	Created     string           `json:"created"`
+}
+
+// saveSubmission stores a submission together with whatever follows from it, in one transaction:
+// a moderation log entry when a rule approved it, otherwise outbox notifications if notify is set
+func (h *Handlers) saveSubmission(record *core.Record, rule *core.Record, notify bool, notice ModerationNotice) error {
+	queued := false
+
+	err := h.app.RunInTransaction(func(txApp core.App) error {
+		if err := txApp.Save(record); err != nil {
+			return err
+		}
+
+		if rule != nil {
+			return logModeration(txApp, actionAutoApprove, record, "rule: "+rule.GetString("name"), "")
+		}
+
+		if !notify {
+			return nil
+		}
+
+		notice.ID = record.Id
+		queued = true
+		return h.enqueueNotifications(txApp, notice)
+	})
+	if err != nil {
+		return err
+	}
+
+	if queued {
+		h.kickOutbox()
+	}
+
+	return nil
+}
+
+// enqueueNotifications writes one outbox row per recipient of every notifier, nothing for a
+// notifier nobody is listening on. Pass the transaction app the submission is saved with so a
+// notification exists if and only if the score does.
+func (h *Handlers) enqueueNotifications(txApp core.App, notice ModerationNotice) error {
+	collection, err := txApp.FindCollectionByNameOrId("outbox")
+	if err != nil {
+		return err
+	}
+
+	for _, notifier := range h.notifiers {
+		recipients, err := notifier.Recipients()
+		if err != nil {
+			return err
+		}
+
+		for _, recipient := range recipients {
+			record := core.NewRecord(collection)
+			record.Set("channel", notifier.Channel())
+			record.Set("recipient", recipient)
+			record.Set("payload", notice)
+			record.Set("status", outboxPending)
+			record.Set("attempts", 0)
+			record.Set("next_attempt", types.NowDateTime())
+
+			if err := txApp.Save(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// kickOutbox delivers anything due straight away instead of waiting for the next cron tick
+func (h *Handlers) kickOutbox() {
+	go func() {
+		if err := h.processOutbox(); err != nil {
+			log.Printf("failed to process outbox: %v", err)
+		}
+	}()
+}
+
+// processOutbox attempts every due delivery. Only one run goes at a time, a run that finds
+// another in progress just returns since that one will pick up the same rows.
+func (h *Handlers) processOutbox() error {
+	if !h.outboxMu.TryLock() {
+		return nil
+	}
+	defer h.outboxMu.Unlock()
+
+	records, err := h.app.FindRecordsByFilter(
+		"outbox",
+		"status = 'pending' && next_attempt <= @now",
+		"next_attempt",
+		100,
+		0,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		h.deliverOutboxEntry(record)
+
+		if err := h.app.Save(record); err != nil {
+			log.Printf("failed to record delivery state for outbox %s: %v", record.Id, err)
+		}
+	}
+
+	return nil
+}
+
+func (h *Handlers) deliverOutboxEntry(record *core.Record) {
+	var notice ModerationNotice
+	err := record.UnmarshalJSONField("payload", &notice)
+
+	if err == nil {
+		err = errUnknownChannel
+		for _, notifier := range h.notifiers {
+			if notifier.Channel() == record.GetString("channel") {
+				err = notifier.Notify(notice, record.GetString("recipient"), h)
+				break
+			}
+		}
+	}
+
+	var deferred *deferredError
+	if errors.As(err, &deferred) {
+		record.Set("next_attempt", deferred.until)
+		return
+	}
+
+	attempts := record.GetInt("attempts") + 1
+	record.Set("attempts", attempts)
+
+	if err == nil {
+		metrics.notifications.inc(record.GetString("channel"), notificationSuccess)
+		record.Set("status", outboxSent)
+		record.Set("sent_at", types.NowDateTime())
+		record.Set("last_error", "")
+		return
+	}
+
+	metrics.notifications.inc(record.GetString("channel"), notificationFailure)
+	record.Set("last_error", err.Error())
+	if attempts >= outboxMaxAttempts || errors.Is(err, errRecipientGone) {
+		record.Set("status", outboxFailed)
+		return
+	}
+
+	record.Set("next_attempt", types.NowDateTime().Add(outboxBackoff(attempts)))
+}
+
+// outboxBackoff is the wait before the next try after the given number of attempts
+func outboxBackoff(attempts int) time.Duration {
+	delay := outboxBaseDelay << (attempts - 1)
+	if delay <= 0 || delay > outboxMaxDelay {
+		return outboxMaxDelay
+	}
+
+	return delay
+}
+
+func (h *Handlers) getOutbox(e *core.RequestEvent) error {
+	status := e.Request.URL.Query().Get("status")
+	if status == "" {
+		status = outboxFailed
+	}
+
+	records, err := h.app.FindRecordsByFilter(
+		"outbox",
+		"status = {:status}",
+		"-updated",
+		200,
+		0,
+		dbx.Params{"status": status},
+	)
+	if err != nil {
+		return e.InternalServerError("Failed to fetch outbox", err)
+	}
+
+	entries := make([]OutboxEntry, len(records))
+	for i, record := range records {
+		entries[i] = outboxEntryFromRecord(record)
+	}
+
+	return e.JSON(http.StatusOK, entries)
+}
+
+// resendOutbox puts a delivery back in the queue with a fresh set of attempts
+func (h *Handlers) resendOutbox(e *core.RequestEvent) error {
+	record, err := h.app.FindRecordById("outbox", e.Request.PathValue("id"))
+	if err != nil {
+		return e.NotFoundError("Delivery not found", err)
+	}
+
+	record.Set("status", outboxPending)
+	record.Set("attempts", 0)
+	record.Set("next_attempt", types.NowDateTime())
+	if err := h.app.Save(record); err != nil {
+		return e.InternalServerError("Failed to queue delivery", err)
+	}
+
+	h.kickOutbox()
+
+	return e.JSON(http.StatusOK, outboxEntryFromRecord(record))
+}
+
+func outboxEntryFromRecord(record *core.Record) OutboxEntry {
+	var notice ModerationNotice
+	_ = record.UnmarshalJSONField("payload", &notice)
+
+	entry := OutboxEntry{
+		ID:        record.Id,
+		Channel:   record.GetString("channel"),
+		Recipient: record.GetString("recipient"),
+		Notice:    notice,
+		Status:    record.GetString("status"),
+		Attempts:  record.GetInt("attempts"),
+		LastError: record.GetString("last_error"),
+		Created:   record.GetDateTime("created").Time().Format(time.RFC3339),
+	}
+
+	if next := record.GetDateTime("next_attempt"); !next.IsZero() {
+		entry.NextAttempt = next.Time().Format(time.RFC3339)
+	}
+	if sent := record.GetDateTime("sent_at"); !sent.IsZero() {
+		entry.SentAt = sent.Time().Format(time.RFC3339)
+	}
+
+	return entry
+}
+
+// outboxPage lists deliveries by status and resends the ones that failed
+func (h *Handlers) outboxPage(e *core.RequestEvent) error {
+	return renderPage(e, http.StatusOK, "outbox", nil)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Moderation pages. Each one is parsed together with layout.html, which holds the page shell
+// and the pieces they share (score details, reason box, footer, and the script the admin pages
+// use to call the JSON API). {{nonce}} is the request's CSP nonce, see security.go.
+//
+//go:embed pages
+var pagesFS embed.FS
+
+var pageTemplates = map[string]*template.Template{}
+
+func init() {
+	entries, err := pagesFS.ReadDir("pages")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, entry := range entries {
+		if entry.Name() == "layout.html" {
+			continue
+		}
+		pageTemplates[entry.Name()] = template.Must(template.New(entry.Name()).
+			Funcs(template.FuncMap{"nonce": func() string { return "" }}).
+			ParseFS(pagesFS, "pages/layout.html", "pages/"+entry.Name()))
+	}
+}
+
+// renderPage renders pages/<name>.html inside the shared layout
+func renderPage(e *core.RequestEvent, status int, name string, data any) error {
+	tmpl, ok := pageTemplates[name+".html"]
+	if !ok {
+		return e.InternalServerError("Unknown page "+name, nil)
+	}
+
+	// Cloned so every request gets its own nonce
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return e.InternalServerError("Failed to render page", err)
+	}
+	tmpl.Funcs(template.FuncMap{"nonce": func() string { return cspNonce(e) }})
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return e.InternalServerError("Failed to render page", err)
+	}
+
+	return e.HTML(status, buf.String())
+}
+
+// Forms on the moderation pages carry a token tied to a per-browser cookie, so a page on another
+// site can't get a moderator's browser to submit one of the signed links behind their back.
+const (
+	csrfCookieName = "moderation_csrf"
+	csrfFieldName  = "csrf_token"
+)
+
+// csrfSecret returns the browser's CSRF cookie, setting a new one if it doesn't have one yet
+func csrfSecret(e *core.RequestEvent) string {
+	if cookie, err := e.Request.Cookie(csrfCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	value := hex.EncodeToString(secret)
+
+	e.SetCookie(&http.Cookie{
+		Name:     csrfCookieName,
+		Value:    value,
+		Path:     "/admin/",
+		HttpOnly: true,
+		Secure:   e.Request.TLS != nil,
+		// Lax so the cookie comes along when a moderator follows a link from an email or chat,
+		// cross-site POSTs still go without it
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return value
+}
+
+// csrfToken is the token for one form, named after what it does, "approve:<id>" for example
+func (h *Handlers) csrfToken(e *core.RequestEvent, form string) string {
+	return h.generateSignature("csrf", form, csrfSecret(e))
+}
+
+// verifyCSRF checks the posted token against the browser's cookie for the given form
+func (h *Handlers) verifyCSRF(e *core.RequestEvent, form string) bool {
+	cookie, err := e.Request.Cookie(csrfCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	return h.verifySignature("csrf", form, cookie.Value, e.Request.FormValue(csrfFieldName))
+}
+
+func csrfError(e *core.RequestEvent) error {
+	metrics.signatureFailures.inc("csrf")
+	return e.ForbiddenError("Invalid or expired form, reload the page and try again", nil)
+}
+
+// moderationPageData is the approve and delete confirmation pages
+type moderationPageData struct {
+	Details       submissionDetails
+	CSRF          string
+	RetentionDays int
+}
+
+type approvedPageData struct {
+	Count     int
+	RenamedTo string
+}
+
+type deletedPageData struct {
+	Discarded     bool
+	RetentionDays int
+	UndoURL       string
+	UndoCSRF      string
+}
+
+type approveAllPageData struct {
+	Entries []submissionDetails
+	Listed  int
+	CSRF    string
+}
+
+type restorePageData struct {
+	Name     string
+	Score    int
+	Restored bool
+	CSRF     string
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// moderationRecipient is a superuser and how they want to hear about submissions. Superusers without
+// a moderator_preferences record get the MODERATION_DELIVERY default and no quiet hours.
+type moderationRecipient struct {
+	Email      string
+	Delivery   string
+	QuietStart int
+	QuietEnd   int
+	Location   *time.Location
+}
+
+// deliveryNone opts a superuser out of moderation notifications entirely
+const deliveryNone = "none"
+
+func (r moderationRecipient) wantsImmediate() bool {
+	return r.Delivery == deliveryImmediate || r.Delivery == deliveryBoth
+}
+
+func (r moderationRecipient) wantsDigest() bool {
+	return r.Delivery == deliveryDigest || r.Delivery == deliveryBoth
+}
+
+// inQuietHours reports whether it's currently inside the recipient's quiet window, which may wrap
+// past midnight (22 to 7). Equal start and end hours mean no quiet hours.
+func (r moderationRecipient) inQuietHours() bool {
+	if r.QuietStart == r.QuietEnd {
+		return false
+	}
+
+	hour := time.Now().In(r.Location).Hour()
+	if r.QuietStart < r.QuietEnd {
+		return hour >= r.QuietStart && hour < r.QuietEnd
+	}
+
+	return hour >= r.QuietStart || hour < r.QuietEnd
+}
+
+// quietHoursEnd is when the recipient's current quiet window is over
+func (r moderationRecipient) quietHoursEnd() time.Time {
+	now := time.Now().In(r.Location)
+
+	end := time.Date(now.Year(), now.Month(), now.Day(), r.QuietEnd, 0, 0, 0, r.Location)
+	if !end.After(now) {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	return end
+}
+
+// moderationRecipients lists every superuser who hasn't opted out, along with their preferences.
+// It's empty, not an error, when there's nobody to notify.
+func (e *EmailService) moderationRecipients() ([]moderationRecipient, error) {
+	superusers, err := e.app.FindAllRecords(core.CollectionNameSuperusers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch admins: %w", err)
+	}
+
+	preferences, err := e.app.FindAllRecords("moderator_preferences")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch moderator preferences: %w", err)
+	}
+
+	bySuperuser := make(map[string]*core.Record, len(preferences))
+	for _, pref := range preferences {
+		bySuperuser[pref.GetString("superuser")] = pref
+	}
+
+	var recipients []moderationRecipient
+	for _, superuser := range superusers {
+		recipient := moderationRecipient{
+			Email:    superuser.Email(),
+			Delivery: moderationDeliveryMode(),
+			Location: time.UTC,
+		}
+
+		if pref, ok := bySuperuser[superuser.Id]; ok {
+			recipient.Delivery = pref.GetString("delivery")
+			recipient.QuietStart = pref.GetInt("quiet_start")
+			recipient.QuietEnd = pref.GetInt("quiet_end")
+
+			if tz := pref.GetString("timezone"); tz != "" {
+				location, err := time.LoadLocation(tz)
+				if err != nil {
+					log.Printf("ignoring invalid timezone %q for %s: %v", tz, recipient.Email, err)
+				} else {
+					recipient.Location = location
+				}
+			}
+		}
+
+		if recipient.Delivery == deliveryNone || recipient.Email == "" {
+			continue
+		}
+
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, nil
+}
+
+// moderationRecipient finds one recipient by email, nil if they're gone or have opted out
+func (e *EmailService) moderationRecipient(email string) (*moderationRecipient, error) {
+	recipients, err := e.moderationRecipients()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, recipient := range recipients {
+		if strings.EqualFold(recipient.Email, email) {
+			return &recipient, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+)
+
+// anonymousName is what players without a usable name are called, it's never protected
+const anonymousName = "Anonymous Player"
+
+// What happens to a submission whose name collides with a reserved one, set with RESERVED_NAME_ACTION
+const (
+	reservedReject = "reject" // refuse it and suggest an alternative
+	reservedFlag   = "flag"   // let it through to moderation with a flag
+)
+
+const flagReservedName = "reserved_name"
+
+const (
+	defaultProtectedTopPlayers = 10
+
+	// Look-alike spellings (same skeleton) of any name this far down the board are refused,
+	// near matches by edit distance only count against the protected top players
+	confusableCheckDepth = 100
+)
+
+func reservedNameAction() string {
+	if os.Getenv("RESERVED_NAME_ACTION") == reservedFlag {
+		return reservedFlag
+	}
+
+	return reservedReject
+}
+
+// protectedTopPlayers is RESERVED_TOP_PLAYERS, how many of the best approved players' names are reserved
+func protectedTopPlayers() int {
+	if n, err := strconv.Atoi(os.Getenv("RESERVED_TOP_PLAYERS")); err == nil && n >= 0 {
+		return n
+	}
+
+	return defaultProtectedTopPlayers
+}
+
+// nameMatchDistance is how many edits apart two name skeletons can be and still count as the
+// same name. Short names need an exact match or ordinary names would collide all the time.
+func nameMatchDistance(skeleton string) int {
+	switch n := len([]rune(skeleton)); {
+	case n <= 4:
+		return 0
+	case n <= 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance is the Levenshtein distance between two strings, counted in runes
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(br)]
+}
+
+// namesCollide reports whether name would pass for the reserved one: the same after folding
+// away look-alikes, case and spacing, or within a few typos of it
+func namesCollide(name, reserved string) bool {
+	a, b := nameSkeleton(name), nameSkeleton(reserved)
+	if a == "" || b == "" {
+		return false
+	}
+
+	return editDistance(a, b) <= nameMatchDistance(b)
+}
+
+// findReservedName returns the reserved name that name collides with, or "" if it's free.
+// Reserved are the admin-managed reserved_names, the protected top players (other than the
+// submitting player) and, for look-alike spellings only, everyone further down the board.
+func (h *Handlers) findReservedName(name, identifier string) (string, error) {
+	if name == anonymousName {
+		return "", nil
+	}
+
+	reserved, err := h.app.FindAllRecords("reserved_names")
+	if err != nil {
+		return "", err
+	}
+	for _, record := range reserved {
+		if namesCollide(name, record.GetString("name")) {
+			return record.GetString("name"), nil
+		}
+	}
+
+	players, err := h.app.FindRecordsByFilter(
+		"leaderboard",
+		"approved = true && shadowed = false && deleted_at = '' && identifier != {:identifier}",
+		"-score",
+		max(confusableCheckDepth, protectedTopPlayers()),
+		0,
+		dbx.Params{"identifier": identifier},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	skeleton := nameSkeleton(name)
+	for i, record := range players {
+		other := record.GetString("name")
+		if other == anonymousName {
+			continue
+		}
+
+		if i < protectedTopPlayers() && namesCollide(name, other) {
+			return other, nil
+		}
+
+		// Same name, different spelling, that's only ever someone pretending
+		if other != name && skeleton != "" && nameSkeleton(other) == skeleton {
+			return other, nil
+		}
+	}
+
+	return "", nil
+}
+
+// suggestName offers a free alternative to a name that collided, or "" if none turned up
+func (h *Handlers) suggestName(name, identifier string) string {
+	base := truncateGraphemes(name, maxNameGraphemes-5)
+
+	for range 5 {
+		candidate := fmt.Sprintf("%s %04d", strings.TrimSpace(base), rand.IntN(10000))
+		if reserved, err := h.findReservedName(candidate, identifier); err == nil && reserved == "" {
+			return candidate
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Re-validation checks stored entries against today's rules, so entries accepted before a rule
+// was tightened or an exploit was fixed get caught. Only published values are checked, a pending
+// improvement still has to get past a moderator.
+
+// Flags for problems that take an entry off the public board
+const (
+	flagOutOfBounds  = "out_of_bounds"
+	flagNameFiltered = "name_filtered" // the name wouldn't make it through sanitizeName as it is now
+	flagBanned       = "banned"
+)
+
+const defaultRevalidationSchedule = "0 4 * * *"
+
+// Reports keep at most this many entries, the counts always cover everything
+const maxReportEntries = 1000
+
+const revalidationModerator = "revalidation"
+
+// What re-validation did to an entry
+const (
+	revalidationUnapproved = "unapproved"
+	revalidationShadowed   = "shadowed"
+	revalidationFlagged    = "flagged"
+)
+
+// revalidationSchedule is REVALIDATION_SCHEDULE, "off" turns the scheduled sweep off
+func revalidationSchedule() string {
+	if schedule := os.Getenv("REVALIDATION_SCHEDULE"); schedule != "" {
+		return schedule
+	}
+
+	return defaultRevalidationSchedule
+}
+
+type RevalidationEntry struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Score    int      `json:"score"`
+	Problems []string `json:"problems"`
+	Action   string   `json:"action"`
+	// An un-approved entry loses its pending improvement, a moderator reviews the entry as it stands
+	DiscardedImprovement bool `json:"discardedImprovement,omitempty"`
+
+	discarded *core.Record // the entry as it was with the improvement, for the moderation log
+}
+
+type RevalidationReport struct {
+	DryRun     bool                `json:"dryRun"`
+	Checked    int                 `json:"checked"`
+	Unapproved int                 `json:"unapproved"`
+	Shadowed   int                 `json:"shadowed"`
+	Flagged    int                 `json:"flagged"`
+	Entries    []RevalidationEntry `json:"entries"`
+}
+
+// revalidateEntries sweeps every entry that isn't in the trash. Entries that break a hard rule
+// (score bounds, name filter, reject ban) are un-approved and go back to moderation, unless a
+// moderator has approved them again since, shadow banned players are shadowed, and anomalies like
+// a score that doesn't add up are only flagged. A dry run reports the same without changing anything.
+func (h *Handlers) revalidateEntries(dryRun bool) (*RevalidationReport, error) {
+	bans, err := h.activeBans()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RevalidationReport{DryRun: dryRun, Entries: []RevalidationEntry{}}
+
+	for offset := 0; ; offset += exportBatchSize {
+		records, err := h.app.FindRecordsByFilter("leaderboard", "deleted_at = ''", "id", exportBatchSize, offset)
+		if err != nil {
+			return report, err
+		}
+
+		for _, record := range records {
+			report.Checked++
+
+			result, changed := h.revalidateEntry(record, bans)
+			if result == nil {
+				continue
+			}
+
+			switch result.Action {
+			case revalidationUnapproved:
+				report.Unapproved++
+			case revalidationShadowed:
+				report.Shadowed++
+			case revalidationFlagged:
+				report.Flagged++
+			}
+			if len(report.Entries) < maxReportEntries {
+				report.Entries = append(report.Entries, *result)
+			}
+
+			if dryRun || !changed {
+				continue
+			}
+
+			err := h.app.RunInTransaction(func(txApp core.App) error {
+				if result.discarded != nil {
+					if err := logModeration(txApp, actionDiscardImprovement, result.discarded, revalidationModerator, "entry un-approved by re-validation"); err != nil {
+						return err
+					}
+				}
+				if err := logModeration(txApp, actionRevalidate, record, revalidationModerator, strings.Join(result.Problems, ", ")); err != nil {
+					return err
+				}
+				return txApp.Save(record)
+			})
+			if err != nil {
+				return report, err
+			}
+		}
+
+		if len(records) < exportBatchSize {
+			break
+		}
+	}
+
+	if !dryRun {
+		if err := h.saveRevalidationReport(report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// revalidateEntry applies the checks to the record in memory. It returns nil for an entry with
+// nothing wrong, and whether the record changed (it may already carry every flag it earned).
+func (h *Handlers) revalidateEntry(record *core.Record, bans []*core.Record) (*RevalidationEntry, bool) {
+	name := record.GetString("name")
+	score := record.GetInt("score")
+
+	hard, soft, shadowBanned := h.entryProblems(record, bans)
+	shadow := shadowBanned && !record.GetBool("shadowed")
+
+	// A moderator who approved the entry after an earlier sweep has already ruled on those problems
+	if len(hard) > 0 && record.GetBool("approved") {
+		overridden, err := h.overriddenProblems(record.Id)
+		if err != nil {
+			log.Printf("failed to check moderation history of %s: %v", record.Id, err)
+		}
+		hard = slices.DeleteFunc(hard, func(problem string) bool { return overridden[problem] })
+	}
+
+	problems := slices.Concat(hard, soft)
+	if len(problems) == 0 && !shadow {
+		return nil, false
+	}
+
+	var flags []string
+	_ = record.UnmarshalJSONField("flags", &flags)
+	changed := false
+	for _, problem := range problems {
+		if !slices.Contains(flags, problem) {
+			flags = append(flags, problem)
+			changed = true
+		}
+	}
+	record.Set("flags", flags)
+
+	result := &RevalidationEntry{ID: record.Id, Name: name, Score: score, Problems: problems}
+
+	switch {
+	case len(hard) > 0 && record.GetBool("approved"):
+		if hasPendingImprovement(record) {
+			result.discarded = record.Clone()
+			result.DiscardedImprovement = true
+			clearPendingImprovement(record)
+		}
+		record.Set("approved", false)
+		record.Set("approval_rule", "")
+		result.Action = revalidationUnapproved
+		changed = true
+	case shadow:
+		record.Set("shadowed", true)
+		result.Problems = append(result.Problems, "shadow_banned")
+		result.Action = revalidationShadowed
+		changed = true
+	case changed:
+		result.Action = revalidationFlagged
+	default:
+		// Already flagged and handled on an earlier run
+		return nil, false
+	}
+
+	return result, changed
+}
+
+// entryProblems checks the entry's published values against the current rules. Hard problems keep
+// it off the public board, soft ones are only worth a flag.
+func (h *Handlers) entryProblems(record *core.Record, bans []*core.Record) (hard, soft []string, shadowBanned bool) {
+	name := record.GetString("name")
+	score := record.GetInt("score")
+	levels := record.GetInt("levels_completed")
+
+	if !scoreInBounds(score, levels) {
+		hard = append(hard, flagOutOfBounds)
+	}
+	// Checked under the locales the name was accepted with, a word allowed in one language may be blocked in another
+	if name != anonymousName && h.sanitizeName(name, splitLocales(record.GetString("name_locales"))...) != name {
+		hard = append(hard, flagNameFiltered)
+	}
+	soft = append(soft, detectAnomalies(score, levels, record.GetInt("completion_time"))...)
+	if hasMixedScriptWord(name) {
+		soft = append(soft, flagMixedScript)
+	}
+
+	if ban := matchBan(bans, record.GetString("identifier"), name, name); ban != nil {
+		switch ban.GetString("mode") {
+		case banModeReject:
+			hard = append(hard, flagBanned)
+		case banModeShadow:
+			shadowBanned = true
+		}
+	}
+
+	return hard, soft, shadowBanned
+}
+
+// overriddenProblems is every problem an earlier sweep logged for the entry that a moderator
+// approved it past afterwards
+func (h *Handlers) overriddenProblems(id string) (map[string]bool, error) {
+	logs, err := h.app.FindRecordsByFilter(
+		"moderation_log",
+		"entry_id = {:id} && (action = {:approve} || action = {:revalidate})",
+		"-created",
+		0,
+		0,
+		dbx.Params{"id": id, "approve": actionApprove, "revalidate": actionRevalidate},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	overridden := map[string]bool{}
+	approvedSince := false
+	for _, entry := range logs {
+		switch entry.GetString("action") {
+		case actionApprove:
+			approvedSince = true
+		case actionRevalidate:
+			if approvedSince {
+				for _, problem := range strings.Split(entry.GetString("reason"), ", ") {
+					overridden[problem] = true
+				}
+			}
+		}
+	}
+
+	return overridden, nil
+}
+
+func (h *Handlers) saveRevalidationReport(report *RevalidationReport) error {
+	collection, err := h.app.FindCollectionByNameOrId("revalidation_reports")
+	if err != nil {
+		return err
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("checked", report.Checked)
+	record.Set("unapproved", report.Unapproved)
+	record.Set("shadowed", report.Shadowed)
+	record.Set("flagged", report.Flagged)
+	record.Set("entries", report.Entries)
+
+	return h.app.Save(record)
+}
+
+// runRevalidation is the scheduled sweep
+func (h *Handlers) runRevalidation() {
+	report, err := h.revalidateEntries(false)
+	if err != nil {
+		log.Printf("failed to re-validate entries: %v", err)
+		return
+	}
+
+	if report.Unapproved+report.Shadowed+report.Flagged > 0 {
+		log.Printf("re-validation checked %d entries: %d un-approved, %d shadowed, %d flagged",
+			report.Checked, report.Unapproved, report.Shadowed, report.Flagged)
+	}
+}
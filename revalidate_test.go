@@ -0,0 +1,150 @@
+// PocketBase's Collection.UnmarshalJSON recurses until the stack overflows under the encoding/json
+// v2 experiment, so tests that run the migrations only build without it
+//go:build !goexperiment.jsonv2
+
+package main
+
+import (
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// newTestHandlers runs every migration on a fresh data dir and loads the built-in word lists
+func newTestHandlers(t *testing.T) *Handlers {
+	t.Helper()
+
+	app := pocketbase.NewWithConfig(pocketbase.Config{DefaultDataDir: t.TempDir()})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.ResetBootstrapState() })
+
+	if err := app.RunAllMigrations(); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandlers(app)
+	h.reloadWordLists()
+
+	return h
+}
+
+func saveTestEntry(t *testing.T, app core.App, fields map[string]any) *core.Record {
+	t.Helper()
+
+	collection, err := app.FindCollectionByNameOrId("leaderboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("identifier", "player_"+core.GenerateDefaultRandomId())
+	record.Set("levels_completed", 3)
+	record.Set("completion_time", 60000)
+	for key, value := range fields {
+		record.Set(key, value)
+	}
+	if err := app.Save(record); err != nil {
+		t.Fatal(err)
+	}
+
+	return record
+}
+
+func TestApproveAfterRevalidationPublishesWhatTheModeratorSees(t *testing.T) {
+	h := newTestHandlers(t)
+
+	// Published under a name the filter blocks, with an improvement waiting on top
+	record := saveTestEntry(t, h.app, map[string]any{
+		"name":                     "shit head",
+		"score":                    300,
+		"approved":                 true,
+		"pending_name":             "Older Pending",
+		"pending_score":            400,
+		"pending_levels_completed": 4,
+		"pending_completion_time":  60000,
+		"pending_submitted":        types.NowDateTime(),
+	})
+
+	report, err := h.revalidateEntries(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Unapproved != 1 || len(report.Entries) != 1 || !report.Entries[0].DiscardedImprovement {
+		t.Fatalf("report = %+v, want the entry un-approved and its improvement discarded", report)
+	}
+
+	record, err = h.app.FindRecordById("leaderboard", record.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.GetBool("approved") || !record.GetDateTime("pending_submitted").IsZero() || record.GetString("pending_name") != "" {
+		t.Fatalf("after re-validation approved = %v, pending = %q %q, want un-approved with nothing pending",
+			record.GetBool("approved"), record.GetString("pending_name"), record.GetDateTime("pending_submitted"))
+	}
+
+	discards, err := h.app.FindRecordsByFilter("moderation_log", "entry_id = {:id} && action = {:action}", "", 0, 0,
+		dbx.Params{"id": record.Id, "action": actionDiscardImprovement})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discards) != 1 {
+		t.Fatalf("got %d discard_improvement log entries, want 1", len(discards))
+	}
+	var snapshot map[string]any
+	if err := discards[0].UnmarshalJSONField("entry", &snapshot); err != nil || snapshot["pending_name"] != "Older Pending" {
+		t.Fatalf("discard snapshot = %v (%v), want the improvement in it", snapshot, err)
+	}
+
+	// What the moderator is shown is the published entry, renaming it and approving publishes exactly that
+	details := submissionDetailsFor(record)
+	if details.IsImprovement || details.Name != "shit head" || details.Score != 300 {
+		t.Fatalf("details = %+v, want the published entry", details)
+	}
+
+	if err := h.approveWithName(record, "Clean Name", "mod@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err = h.app.FindRecordById("leaderboard", record.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !record.GetBool("approved") || record.GetString("name") != "Clean Name" || record.GetInt("score") != 300 {
+		t.Fatalf("after approval approved = %v, name = %q, score = %d, want Clean Name with 300 published",
+			record.GetBool("approved"), record.GetString("name"), record.GetInt("score"))
+	}
+}
+
+func TestApproveIgnoresStalePendingOnUnapprovedEntry(t *testing.T) {
+	h := newTestHandlers(t)
+
+	// Written before unapproved entries had their improvements cleared
+	record := saveTestEntry(t, h.app, map[string]any{
+		"name":              "Newest Name",
+		"score":             500,
+		"levels_completed":  5,
+		"approved":          false,
+		"pending_name":      "Stale Name",
+		"pending_score":     900,
+		"pending_submitted": types.NowDateTime(),
+	})
+
+	if got := bestKnownScore(record); got != 500 {
+		t.Fatalf("bestKnownScore = %d, want 500", got)
+	}
+
+	if err := h.app.RunInTransaction(func(txApp core.App) error {
+		return approveEntry(txApp, record, "mod@example.com", "")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if record.GetString("name") != "Newest Name" || record.GetInt("score") != 500 {
+		t.Fatalf("published %q with %d, want Newest Name with 500", record.GetString("name"), record.GetInt("score"))
+	}
+}
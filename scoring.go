@@ -0,0 +1,67 @@
+package main
+
+// Anomaly flags attached to a submission when something about it looks off
+const (
+	flagScoreMismatch = "score_mismatch"
+	flagMissingTime   = "missing_time"
+	flagTooFast       = "too_fast"
+	flagMixedScript   = "mixed_script" // a word in the name mixes look-alike scripts, see names.go
+)
+
+// The most a submission can claim, anything beyond is refused outright
+const (
+	maxScore  = 10000
+	maxLevels = 20
+)
+
+func scoreInBounds(score, levelsCompleted int) bool {
+	return score >= 0 && score <= maxScore && levelsCompleted >= 0 && levelsCompleted <= maxLevels
+}
+
+// Nobody reads a cookie banner and finds the reject button faster than this
+const minLevelTimeMs = 2000
+
+// calculateScore mirrors LeaderboardService.calculateScore in the frontend so
+// submissions can be checked against what the game would have awarded
+func calculateScore(levelsCompleted, completionTimeMs int) int {
+	// Base score: 100 points per level
+	score := levelsCompleted * 100
+
+	// Time bonus: up to 50 points per level for fast completion
+	timeBonus := max(0, 50-completionTimeMs/1000/10) * levelsCompleted
+
+	// Perfect completion bonus (all 20 levels)
+	if levelsCompleted == 20 {
+		score += 1000
+	}
+
+	// Milestone bonuses
+	if levelsCompleted >= 10 {
+		score += 200
+	}
+	if levelsCompleted >= 15 {
+		score += 300
+	}
+	if levelsCompleted >= 18 {
+		score += 500
+	}
+
+	return score + timeBonus
+}
+
+// detectAnomalies returns the flags for anything in a submission that doesn't add up
+func detectAnomalies(score, levelsCompleted, completionTimeMs int) []string {
+	flags := []string{}
+
+	if score != calculateScore(levelsCompleted, completionTimeMs) {
+		flags = append(flags, flagScoreMismatch)
+	}
+
+	if levelsCompleted > 0 && completionTimeMs <= 0 {
+		flags = append(flags, flagMissingTime)
+	} else if completionTimeMs < levelsCompleted*minLevelTimeMs {
+		flags = append(flags, flagTooFast)
+	}
+
+	return flags
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// Security headers are configured per route group with SECURITY_<GROUP>_<SETTING>, falling back
+// to SECURITY_<SETTING> for every group and then to the defaults below. Settings are CSP,
+// FRAME_OPTIONS, REFERRER_POLICY, PERMISSIONS_POLICY, COOP and HSTS, and "off" leaves a header
+// out. {nonce} in a CSP is replaced with a fresh nonce for every request.
+//
+// HSTS is only ever sent over HTTPS, directly or behind a proxy that sets X-Forwarded-Proto.
+
+// Route groups with their own policy
+const (
+	securityGroupSPA       = "spa"       // the game and its assets
+	securityGroupAdmin     = "admin"     // the moderation pages and the dev mail viewer
+	securityGroupAPI       = "api"       // JSON endpoints, ours and PocketBase's, and /metrics
+	securityGroupDashboard = "dashboard" // the PocketBase dashboard, which brings its own CSP
+)
+
+const securityHeadersMiddlewareId = "securityHeaders"
+
+const cspNonceKey = "cspNonce"
+
+// The game loads its analytics script from a.dbuidl.com and FontAwesome adds a <style> element
+// at runtime, hence the unsafe-inline styles
+const defaultSPACSP = "default-src 'self'; script-src 'self' https://a.dbuidl.com; " +
+	"style-src 'self' 'unsafe-inline'; img-src 'self' data:; font-src 'self' data:; " +
+	"connect-src 'self' https://a.dbuidl.com; object-src 'none'; base-uri 'self'; " +
+	"form-action 'self'; frame-ancestors 'none'"
+
+// The moderation pages only run their own nonced <style> and <script> elements
+const defaultAdminCSP = "default-src 'none'; script-src 'nonce-{nonce}'; style-src 'nonce-{nonce}'; " +
+	"img-src 'self' data:; connect-src 'self'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'"
+
+const defaultAPICSP = "default-src 'none'; frame-ancestors 'none'"
+
+var defaultSecurityHeaders = map[string]map[string]string{
+	securityGroupSPA: {
+		"CSP":             defaultSPACSP,
+		"FRAME_OPTIONS":   "DENY",
+		"REFERRER_POLICY": "strict-origin-when-cross-origin",
+	},
+	// The confirmation pages have signed URLs in the address bar, which mustn't leak to other
+	// sites through the Referer header
+	securityGroupAdmin: {
+		"CSP":             defaultAdminCSP,
+		"FRAME_OPTIONS":   "DENY",
+		"REFERRER_POLICY": "no-referrer",
+	},
+	securityGroupAPI: {
+		"CSP":             defaultAPICSP,
+		"FRAME_OPTIONS":   "DENY",
+		"REFERRER_POLICY": "no-referrer",
+	},
+	securityGroupDashboard: {
+		"CSP":             "off",
+		"FRAME_OPTIONS":   "SAMEORIGIN",
+		"REFERRER_POLICY": "same-origin",
+	},
+}
+
+// Defaults shared by every group
+var defaultSecuritySettings = map[string]string{
+	"PERMISSIONS_POLICY": "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+	"COOP":               "same-origin",
+	"HSTS":               "max-age=31536000; includeSubDomains",
+}
+
+// Header for each setting
+var securityHeaderNames = map[string]string{
+	"CSP":                "Content-Security-Policy",
+	"FRAME_OPTIONS":      "X-Frame-Options",
+	"REFERRER_POLICY":    "Referrer-Policy",
+	"PERMISSIONS_POLICY": "Permissions-Policy",
+	"COOP":               "Cross-Origin-Opener-Policy",
+	"HSTS":               "Strict-Transport-Security",
+}
+
+// securityGroup works out which policy a request falls under from its path
+func securityGroup(r *http.Request) string {
+	switch path := r.URL.Path; {
+	case strings.HasPrefix(path, "/admin/"), strings.HasPrefix(path, "/_dev/"):
+		return securityGroupAdmin
+	case strings.HasPrefix(path, "/api/"), path == "/metrics":
+		return securityGroupAPI
+	case path == "/_" || strings.HasPrefix(path, "/_/"):
+		return securityGroupDashboard
+	default:
+		return securityGroupSPA
+	}
+}
+
+// securitySetting looks up SECURITY_<GROUP>_<name>, then SECURITY_<name>, then the defaults
+func securitySetting(group, name string) string {
+	if value := os.Getenv("SECURITY_" + strings.ToUpper(group) + "_" + name); value != "" {
+		return value
+	}
+	if value := os.Getenv("SECURITY_" + name); value != "" {
+		return value
+	}
+	if value, ok := defaultSecurityHeaders[group][name]; ok {
+		return value
+	}
+
+	return defaultSecuritySettings[name]
+}
+
+// securityHeaders resolves every header for a group, leaving out the ones turned off
+func securityHeaders(group string) map[string]string {
+	headers := map[string]string{}
+	for name, header := range securityHeaderNames {
+		if value := securitySetting(group, name); value != "" && value != "off" {
+			headers[header] = value
+		}
+	}
+
+	return headers
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// securityHeadersMiddleware sets the headers for each request's route group before the handler
+// runs, so a handler can still adjust them (see allowInlineStyleAttributes)
+func securityHeadersMiddleware() *hook.Handler[*core.RequestEvent] {
+	policies := map[string]map[string]string{}
+	for _, group := range []string{securityGroupSPA, securityGroupAdmin, securityGroupAPI, securityGroupDashboard} {
+		policies[group] = securityHeaders(group)
+	}
+
+	return &hook.Handler[*core.RequestEvent]{
+		Id: securityHeadersMiddlewareId,
+		Func: func(e *core.RequestEvent) error {
+			header := e.Response.Header()
+
+			for name, value := range policies[securityGroup(e.Request)] {
+				switch name {
+				case "Strict-Transport-Security":
+					if !isHTTPS(e.Request) {
+						continue
+					}
+				case "Content-Security-Policy":
+					if strings.Contains(value, "{nonce}") {
+						value = strings.ReplaceAll(value, "{nonce}", newCSPNonce(e))
+					}
+				}
+				header.Set(name, value)
+			}
+
+			return e.Next()
+		},
+	}
+}
+
+func newCSPNonce(e *core.RequestEvent) string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	value := hex.EncodeToString(nonce)
+	e.Set(cspNonceKey, value)
+
+	return value
+}
+
+// cspNonce is the current request's nonce, empty when its policy doesn't use one
+func cspNonce(e *core.RequestEvent) string {
+	nonce, _ := e.Get(cspNonceKey).(string)
+	return nonce
+}
+
+// allowInlineStyleAttributes relaxes the CSP for a page showing an email, which is styled with
+// style attributes throughout as mail clients expect. Scripts stay blocked.
+func allowInlineStyleAttributes(e *core.RequestEvent) {
+	header := e.Response.Header()
+	if csp := header.Get("Content-Security-Policy"); csp != "" {
+		header.Set("Content-Security-Policy", csp+"; style-src-attr 'unsafe-inline'")
+	}
+}
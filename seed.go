@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Fake players for load and UI testing. The same seed always produces the same players, their
+// created dates counting back from the day it runs. They all have identifiers starting with
+// seedIdentifierPrefix so they can be told apart from real ones and replaced on the next run.
+
+const seedIdentifierPrefix = "seed:"
+
+const seedBatchSize = 1000
+
+const seedIdAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// How players actually get on: most give up somewhere along the way, and a level takes a few
+// seconds once you know where the reject button hides
+const (
+	seedLevelContinueChance = 0.85
+	seedLevelTimeMedianMs   = 9000
+	seedLevelTimeSpread     = 0.6 // sigma of the log-normal level time
+	seedHistoryDays         = 180
+)
+
+var seedAdjectives = []string{
+	"Sneaky", "Grumpy", "Swift", "Silent", "Crispy", "Fuzzy", "Lucky", "Brave", "Salty", "Sleepy",
+	"Clever", "Cosmic", "Rapid", "Tiny", "Mighty", "Jolly", "Shady", "Noble", "Wild", "Quiet",
+	"Dark", "Golden", "Rusty", "Frosty", "Spicy", "Chunky", "Witty", "Bold", "Curious", "Patient",
+}
+
+var seedNouns = []string{
+	"Cookie", "Banner", "Clicker", "Tracker", "Pixel", "Consent", "Badger", "Otter", "Falcon", "Muffin",
+	"Wizard", "Ninja", "Pirate", "Panda", "Robot", "Biscuit", "Crumb", "Popup", "Toggle", "Checkbox",
+	"Penguin", "Llama", "Fox", "Raven", "Walrus", "Gecko", "Moose", "Button", "Modal", "Lynx",
+}
+
+type seedOptions struct {
+	Players       int
+	ApprovedRatio float64
+	Seed          uint64
+}
+
+// seedLeaderboard replaces any previously seeded players with opts.Players new ones
+func seedLeaderboard(app core.App, opts seedOptions, progress io.Writer) error {
+	if opts.Players < 0 {
+		return errors.New("players can't be negative")
+	}
+	if opts.ApprovedRatio < 0 || opts.ApprovedRatio > 1 {
+		return errors.New("approved ratio must be between 0 and 1")
+	}
+
+	collection, err := app.FindCollectionByNameOrId("leaderboard")
+	if err != nil {
+		return err
+	}
+
+	removed, err := clearSeededPlayers(app)
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		fmt.Fprintf(progress, "removed %d previously seeded players\n", removed)
+	}
+
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x9e3779b97f4a7c15))
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+
+	for start := 0; start < opts.Players; start += seedBatchSize {
+		end := min(start+seedBatchSize, opts.Players)
+
+		err := app.RunInTransaction(func(txApp core.App) error {
+			for i := start; i < end; i++ {
+				record := core.NewRecord(collection)
+				fillSeedPlayer(record, rng, i, opts, now)
+				if err := txApp.Save(record); err != nil {
+					return fmt.Errorf("player %d: %w", i, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(progress, "seeded %d/%d players\n", end, opts.Players)
+	}
+
+	return nil
+}
+
+func clearSeededPlayers(app core.App) (int, error) {
+	removed := 0
+	for {
+		records, err := app.FindRecordsByFilter(
+			"leaderboard",
+			"identifier ~ {:prefix}",
+			"",
+			seedBatchSize,
+			0,
+			dbx.Params{"prefix": seedIdentifierPrefix + "%"},
+		)
+		if err != nil {
+			return removed, err
+		}
+		if len(records) == 0 {
+			return removed, nil
+		}
+
+		err = app.RunInTransaction(func(txApp core.App) error {
+			for _, record := range records {
+				if err := txApp.Delete(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return removed, err
+		}
+		removed += len(records)
+	}
+}
+
+func fillSeedPlayer(record *core.Record, rng *rand.Rand, i int, opts seedOptions, now time.Time) {
+	levels := 1
+	for levels < 20 && rng.Float64() < seedLevelContinueChance {
+		levels++
+	}
+
+	completionTime := 0
+	for range levels {
+		levelTime := seedLevelTimeMedianMs * math.Exp(rng.NormFloat64()*seedLevelTimeSpread)
+		completionTime += max(minLevelTimeMs+500, int(levelTime))
+	}
+
+	score := calculateScore(levels, completionTime)
+	created := now.Add(-time.Duration(rng.Int64N(int64(seedHistoryDays * 24 * time.Hour))))
+
+	id := make([]byte, 15)
+	for j := range id {
+		id[j] = seedIdAlphabet[rng.IntN(len(seedIdAlphabet))]
+	}
+	record.Id = string(id)
+
+	record.Set("name", seedPlayerName(rng))
+	record.Set("identifier", fmt.Sprintf("%s%d:%d", seedIdentifierPrefix, opts.Seed, i))
+	record.Set("score", score)
+	record.Set("levels_completed", levels)
+	record.Set("completion_time", completionTime)
+	record.Set("approved", rng.Float64() < opts.ApprovedRatio)
+	record.Set("flags", detectAnomalies(score, levels, completionTime))
+	if dt, err := types.ParseDateTime(created); err == nil {
+		record.SetRaw("created", dt)
+	}
+}
+
+// seedPlayerName makes up a gamer-ish name like "Sneaky Otter", "CrispyPixel" or "Lucky Badger 42"
+func seedPlayerName(rng *rand.Rand) string {
+	adjective := seedAdjectives[rng.IntN(len(seedAdjectives))]
+	noun := seedNouns[rng.IntN(len(seedNouns))]
+
+	switch rng.IntN(4) {
+	case 0:
+		return adjective + noun
+	case 1:
+		return fmt.Sprintf("%s %s %d", adjective, noun, rng.IntN(100))
+	case 2:
+		return fmt.Sprintf("%s%d", strings.ToLower(noun), 1970+rng.IntN(40))
+	default:
+		return adjective + " " + noun
+	}
+}
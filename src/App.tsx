@@ -77,6 +77,8 @@ function App(): JSX.Element {
   const [gameScore, setGameScore] = useState(0);
   const [gameCompletionTime, setGameCompletionTime] = useState(0);
   const [submittedScore, setSubmittedScore] = useState(false);
+  const [nameError, setNameError] = useState<string | undefined>(undefined);
+  const [suggestedName, setSuggestedName] = useState<string | undefined>(undefined);
   
   // Toast notification system
   const { toasts, addRandomToast, dismissToast, clearAllToasts } = useToasts();
@@ -99,18 +101,35 @@ function App(): JSX.Element {
   };
 
   const handleNameSubmit = async (name: string) => {
-    const success = await LeaderboardService.addScore({
+    const result = await LeaderboardService.addScore({
       name,
       identifier: playerId,
       score: gameScore,
       levelsCompleted: gameLevel - 1,
       completionTime: gameCompletionTime
     });
-    
+
+    // Keep the modal open so the player can take the suggestion or try another name
+    if (result.nameTaken) {
+      setNameError(result.message || 'That name is taken, please pick a different one');
+      setSuggestedName(result.suggestedName);
+      return;
+    }
+
+    // Tell the player rather than quietly keeping the score on this device
+    if (result.refused) {
+      setNameError(result.message || 'Your score could not be submitted');
+      setSuggestedName(undefined);
+      setSubmittedScore(true);
+      return;
+    }
+
+    setNameError(undefined);
+    setSuggestedName(undefined);
     setSubmittedScore(true);
     setShowNameModal(false);
     
-    if (success) {
+    if (result.success) {
       // Optional: Show success message
       console.log('Score submitted successfully!');
     }
@@ -401,6 +420,8 @@ function App(): JSX.Element {
             isOpen={showNameModal}
             score={gameScore}
             levelsCompleted={gameLevel - 1}
+            error={nameError}
+            suggestedName={suggestedName}
             onSubmit={handleNameSubmit}
             onCancel={() => setShowNameModal(false)}
         />
@@ -1,11 +1,13 @@
-import { useState } from 'preact/hooks';
+import { useState, useEffect } from 'preact/hooks';
 import { JSX } from 'preact';
 
 interface INameRegistrationModalProps {
     isOpen: boolean;
     score: number;
     levelsCompleted: number;
-    onSubmit: (name: string) => void;
+    error?: string; // Why the last name was refused
+    suggestedName?: string; // Filled in for the player when their name was refused
+    onSubmit: (name: string) => Promise<void>;
     onCancel: () => void;
 }
 
@@ -13,12 +15,20 @@ export default function NameRegistrationModal({
     isOpen, 
     score, 
     levelsCompleted, 
+    error,
+    suggestedName,
     onSubmit, 
     onCancel 
 }: INameRegistrationModalProps): JSX.Element | null {
     const [name, setName] = useState('');
     const [isSubmitting, setIsSubmitting] = useState(false);
 
+    useEffect(() => {
+        if (suggestedName) {
+            setName(suggestedName);
+        }
+    }, [suggestedName]);
+
     if (!isOpen) return null;
 
     const handleSubmit = async (e: Event) => {
@@ -26,7 +36,8 @@ export default function NameRegistrationModal({
         if (name.trim().length < 2) return;
         
         setIsSubmitting(true);
-        onSubmit(name.trim());
+        await onSubmit(name.trim());
+        setIsSubmitting(false);
     };
 
     const getScoreRating = () => {
@@ -72,6 +83,12 @@ export default function NameRegistrationModal({
                             disabled={isSubmitting}
                             autoFocus
                         />
+                        {error && (
+                            <div className="text-sm text-red-600 mt-2">
+                                {error}
+                                {suggestedName && <span> How about "{suggestedName}"?</span>}
+                            </div>
+                        )}
                         <div className="text-xs text-gray-500 mt-1">
                             Names are moderated before appearing on the leaderboard
                         </div>
@@ -99,6 +99,11 @@ export default function RegionBlockedLeaderboard({ playerId }: IRegionBlockedLea
                                 Out of {playerStats.totalPlayers} total players
                             </div>
                         )}
+                        {playerStats.renamedFrom && (
+                            <div className="text-center mt-2 text-sm text-amber-700">
+                                A moderator changed your name from "{playerStats.renamedFrom}" to "{playerStats.entry.name}".
+                            </div>
+                        )}
                     </div>
                 )}
 
@@ -16,10 +16,19 @@ interface IScoreSubmission {
     completionTime?: number;
 }
 
+export interface ISubmitResult {
+    success: boolean;
+    nameTaken?: boolean; // The name is reserved, the player has to pick another
+    refused?: boolean; // The player is banned or their entry was removed, there's no point retrying
+    message?: string;
+    suggestedName?: string; // A free name to offer instead, may be missing
+}
+
 export interface IPlayerStats {
     rank: number | null;
     totalPlayers: number;
     entry: ILeaderboardEntry | null;
+    renamedFrom?: string; // Set when a moderator changed the player's name
 }
 
 export class LeaderboardService {
@@ -75,7 +84,7 @@ export class LeaderboardService {
         }
     }
 
-    static async addScore(entry: IScoreSubmission): Promise<boolean> {
+    static async addScore(entry: IScoreSubmission): Promise<ISubmitResult> {
         try {
             const response = await fetch('/api/leaderboard/submit', {
                 method: 'POST',
@@ -91,16 +100,32 @@ export class LeaderboardService {
                 })
             });
 
+            // The backend is up and refused the name, saving it locally would only hide that
+            if (response.status === 409) {
+                const result = await response.json().catch(() => ({}));
+                return {
+                    success: false,
+                    nameTaken: true,
+                    message: result.message,
+                    suggestedName: result.suggestedName || undefined
+                };
+            }
+
+            if (response.status === 403) {
+                const result = await response.json().catch(() => ({}));
+                return { success: false, refused: true, message: result.message };
+            }
+
             if (!response.ok) {
                 throw new Error(`HTTP ${response.status}`);
             }
 
             const result = await response.json();
-            return result.success || false;
+            return { success: result.success || false };
         } catch (error) {
             console.warn('Failed to save score to backend:', error);
             // Fall back to localStorage
-            return this.addScoreLocally(entry);
+            return { success: this.addScoreLocally(entry) };
         }
     }
 
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Leaderboard export and import, for moving entries between instances and handing out datasets.
+// Player identifiers never leave the server, so imported entries that don't exist yet get a
+// placeholder identifier and can't be claimed by the player's browser.
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+// What an import does with an entry that already exists (same id)
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictKeepBest  = "keep-best" // overwrite only if the imported score is higher
+)
+
+const exportBatchSize = 500
+
+// defaultExportStatus is what the API and the command line export without a status
+const defaultExportStatus = "all"
+
+// importedIdentifierPrefix marks the identifier of an entry created by an import
+const importedIdentifierPrefix = "import:"
+
+// importModerator is who the moderation log names for an entry an import overwrote
+const importModerator = "import"
+
+// ExportRow is one entry as it's exported, the identifier left out
+type ExportRow struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Score           int      `json:"score"`
+	LevelsCompleted int      `json:"levelsCompleted"`
+	CompletionTime  int      `json:"completionTime"`
+	Approved        bool     `json:"approved"`
+	Shadowed        bool     `json:"shadowed"`
+	Flags           []string `json:"flags"`
+	RenamedFrom     string   `json:"renamedFrom"`
+	Created         string   `json:"created"`
+	DeletedAt       string   `json:"deletedAt"`
+}
+
+// The CSV header, the same names as the NDJSON keys. Flags are joined with ";".
+var exportColumns = []string{
+	"id", "name", "score", "levelsCompleted", "completionTime", "approved", "shadowed",
+	"flags", "renamedFrom", "created", "deletedAt",
+}
+
+type exportOptions struct {
+	Status string // one of the API statuses, "all" (the default) for everything including the trash
+	Since  string // created on or after, a date or RFC 3339 time
+	Until  string // created before
+}
+
+type ImportError struct {
+	Line    int    `json:"line"`
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportReport is what an import did, or would have done for a dry run. Nothing is written
+// unless every row imports cleanly.
+type ImportReport struct {
+	DryRun  bool `json:"dryRun"`
+	Applied bool `json:"applied"`
+	Created int  `json:"created"`
+	Updated int  `json:"updated"`
+	Skipped int  `json:"skipped"`
+	// Imported entries that failed a check, flagged and, for the problems that keep an entry off
+	// the public board, left unapproved whatever the file says
+	Flagged    int           `json:"flagged"`
+	Unapproved int           `json:"unapproved"`
+	Errors     []ImportError `json:"errors"`
+}
+
+var errImportRollback = errors.New("import rolled back")
+
+func exportRowFor(record *core.Record) ExportRow {
+	row := ExportRow{
+		ID:              record.Id,
+		Name:            record.GetString("name"),
+		Score:           record.GetInt("score"),
+		LevelsCompleted: record.GetInt("levels_completed"),
+		CompletionTime:  record.GetInt("completion_time"),
+		Approved:        record.GetBool("approved"),
+		Shadowed:        record.GetBool("shadowed"),
+		Flags:           []string{},
+		RenamedFrom:     record.GetString("renamed_from"),
+		Created:         record.GetDateTime("created").Time().Format(time.RFC3339),
+	}
+	_ = record.UnmarshalJSONField("flags", &row.Flags)
+
+	if isDeleted(record) {
+		row.DeletedAt = record.GetDateTime("deleted_at").Time().Format(time.RFC3339)
+	}
+
+	return row
+}
+
+func (row ExportRow) csvRecord() []string {
+	return []string{
+		row.ID,
+		row.Name,
+		strconv.Itoa(row.Score),
+		strconv.Itoa(row.LevelsCompleted),
+		strconv.Itoa(row.CompletionTime),
+		strconv.FormatBool(row.Approved),
+		strconv.FormatBool(row.Shadowed),
+		strings.Join(row.Flags, ";"),
+		row.RenamedFrom,
+		row.Created,
+		row.DeletedAt,
+	}
+}
+
+// parseCSVRow reads a row by column name, so columns can come in any order and missing ones are left empty
+func parseCSVRow(columns map[string]int, fields []string) (ExportRow, error) {
+	get := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+
+	row := ExportRow{
+		ID:          get("id"),
+		Name:        get("name"),
+		RenamedFrom: get("renamedFrom"),
+		Created:     get("created"),
+		DeletedAt:   get("deletedAt"),
+	}
+
+	var err error
+	for name, dst := range map[string]*int{"score": &row.Score, "levelsCompleted": &row.LevelsCompleted, "completionTime": &row.CompletionTime} {
+		if value := get(name); value != "" {
+			if *dst, err = strconv.Atoi(value); err != nil {
+				return row, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	for name, dst := range map[string]*bool{"approved": &row.Approved, "shadowed": &row.Shadowed} {
+		if value := get(name); value != "" {
+			if *dst, err = strconv.ParseBool(value); err != nil {
+				return row, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	if flags := get("flags"); flags != "" {
+		row.Flags = strings.Split(flags, ";")
+	}
+
+	return row, nil
+}
+
+// parseExportDate takes a plain date ("2025-09-01") or anything PocketBase reads as a date
+func parseExportDate(value string) (types.DateTime, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return types.ParseDateTime(t)
+	}
+
+	return types.ParseDateTime(value)
+}
+
+func exportFilter(opts exportOptions) (string, dbx.Params, error) {
+	if opts.Status == "" {
+		opts.Status = defaultExportStatus
+	}
+
+	filter, ok := statusFilters[opts.Status]
+	if opts.Status == "all" {
+		filter, ok = "id != ''", true
+	}
+	if !ok {
+		return "", nil, fmt.Errorf("unknown status %q, use pending, approved, shadowed, deleted or all", opts.Status)
+	}
+
+	params := dbx.Params{}
+	if opts.Since != "" {
+		since, err := parseExportDate(opts.Since)
+		if err != nil {
+			return "", nil, fmt.Errorf("since: %w", err)
+		}
+		filter += " && created >= {:since}"
+		params["since"] = since.String()
+	}
+	if opts.Until != "" {
+		until, err := parseExportDate(opts.Until)
+		if err != nil {
+			return "", nil, fmt.Errorf("until: %w", err)
+		}
+		filter += " && created < {:until}"
+		params["until"] = until.String()
+	}
+
+	return filter, params, nil
+}
+
+// exportEntries writes every entry matching opts to w, a batch at a time, and returns how many it wrote
+func exportEntries(app core.App, w io.Writer, format string, opts exportOptions) (int, error) {
+	filter, params, err := exportFilter(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var write func(ExportRow) error
+	var flush func() error
+	switch format {
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportColumns); err != nil {
+			return 0, err
+		}
+		write = func(row ExportRow) error { return cw.Write(row.csvRecord()) }
+		flush = func() error { cw.Flush(); return cw.Error() }
+	case formatNDJSON:
+		enc := json.NewEncoder(w)
+		write = func(row ExportRow) error { return enc.Encode(row) }
+		flush = func() error { return nil }
+	default:
+		return 0, fmt.Errorf("unknown format %q, use csv or ndjson", format)
+	}
+
+	count := 0
+	for offset := 0; ; offset += exportBatchSize {
+		records, err := app.FindRecordsByFilter("leaderboard", filter, "created,id", exportBatchSize, offset, params)
+		if err != nil {
+			return count, err
+		}
+
+		for _, record := range records {
+			if err := write(exportRowFor(record)); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if len(records) < exportBatchSize {
+			break
+		}
+	}
+
+	return count, flush()
+}
+
+type importRow struct {
+	line int
+	row  ExportRow
+	err  error
+}
+
+// readImportRows parses the whole input, keeping rows that don't parse so they end up in the report
+func readImportRows(r io.Reader, format string) ([]importRow, error) {
+	var rows []importRow
+
+	switch format {
+	case formatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+
+		header, err := cr.Read()
+		if err != nil {
+			return nil, fmt.Errorf("reading header: %w", err)
+		}
+		columns := map[string]int{}
+		for i, name := range header {
+			columns[strings.TrimSpace(name)] = i
+		}
+
+		for line := 2; ; line++ {
+			fields, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				rows = append(rows, importRow{line: line, err: err})
+				continue
+			}
+
+			row, err := parseCSVRow(columns, fields)
+			rows = append(rows, importRow{line: line, row: row, err: err})
+		}
+	case formatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+
+			var row ExportRow
+			err := json.Unmarshal([]byte(text), &row)
+			rows = append(rows, importRow{line: line, row: row, err: err})
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q, use csv or ndjson", format)
+	}
+
+	return rows, nil
+}
+
+// importEntries adds the entries in r to the leaderboard, resolving entries that already exist
+// with the conflict strategy. It all happens in one transaction, rolled back for a dry run or if
+// any row fails.
+func (h *Handlers) importEntries(r io.Reader, format, conflict string, dryRun bool) (*ImportReport, error) {
+	if conflict != conflictSkip && conflict != conflictOverwrite && conflict != conflictKeepBest {
+		return nil, fmt.Errorf("unknown conflict strategy %q, use skip, overwrite or keep-best", conflict)
+	}
+
+	rows, err := readImportRows(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	bans, err := h.activeBans()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{DryRun: dryRun, Errors: []ImportError{}}
+
+	err = h.app.RunInTransaction(func(txApp core.App) error {
+		collection, err := txApp.FindCollectionByNameOrId("leaderboard")
+		if err != nil {
+			return err
+		}
+
+		for _, item := range rows {
+			if item.err == nil {
+				item.err = h.importEntry(txApp, collection, item.row, conflict, bans, report)
+			}
+			if item.err != nil {
+				report.Errors = append(report.Errors, ImportError{Line: item.line, ID: item.row.ID, Message: item.err.Error()})
+			}
+		}
+
+		if dryRun || len(report.Errors) > 0 {
+			return errImportRollback
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRollback) {
+		return nil, err
+	}
+
+	report.Applied = err == nil
+	return report, nil
+}
+
+func (h *Handlers) importEntry(txApp core.App, collection *core.Collection, row ExportRow, conflict string, bans []*core.Record, report *ImportReport) error {
+	if strings.TrimSpace(row.Name) == "" {
+		return errors.New("name is required")
+	}
+	if row.Score < 0 || row.LevelsCompleted < 0 || row.CompletionTime < 0 {
+		return errors.New("score, levels and completion time can't be negative")
+	}
+
+	var record *core.Record
+	if row.ID != "" {
+		record, _ = txApp.FindRecordById(collection, row.ID)
+	}
+
+	if record != nil {
+		switch {
+		case conflict == conflictSkip,
+			conflict == conflictKeepBest && row.Score <= record.GetInt("score"):
+			report.Skipped++
+			return nil
+		}
+
+		// The file replaces the entry and everything moderators did to it, the log keeps what was there
+		if err := logModeration(txApp, actionImport, record, importModerator, "overwritten by an import ("+conflict+")"); err != nil {
+			return err
+		}
+	} else {
+		record = core.NewRecord(collection)
+		if row.ID == "" {
+			row.ID = core.GenerateDefaultRandomId()
+		}
+		record.Id = row.ID
+		record.Set("identifier", importedIdentifierPrefix+row.ID)
+
+		if row.Created != "" {
+			created, err := types.ParseDateTime(row.Created)
+			if err != nil {
+				return fmt.Errorf("created: %w", err)
+			}
+			record.SetRaw("created", created)
+		}
+	}
+
+	deletedAt := types.DateTime{}
+	if row.DeletedAt != "" {
+		var err error
+		if deletedAt, err = types.ParseDateTime(row.DeletedAt); err != nil {
+			return fmt.Errorf("deletedAt: %w", err)
+		}
+	}
+
+	record.Set("name", row.Name)
+	record.Set("score", row.Score)
+	record.Set("levels_completed", row.LevelsCompleted)
+	record.Set("completion_time", row.CompletionTime)
+	record.Set("approved", row.Approved)
+	record.Set("shadowed", row.Shadowed)
+	if row.Flags == nil {
+		row.Flags = []string{}
+	}
+	record.Set("renamed_from", row.RenamedFrom)
+	record.Set("name_locales", "")
+	record.Set("approval_rule", "") // Approved or not as the file says, not by a rule
+	record.Set("deleted_at", deletedAt)
+	clearPendingImprovement(record)
+
+	// The file's approved and shadowed are where the entry starts, not a ruling. It goes through the
+	// same checks as a submission, and whatever fails is flagged for a moderator to look at.
+	hard, soft, shadowBanned := h.entryProblems(record, bans)
+	problems := slices.Concat(hard, soft)
+	for _, problem := range problems {
+		if !slices.Contains(row.Flags, problem) {
+			row.Flags = append(row.Flags, problem)
+		}
+	}
+	record.Set("flags", row.Flags)
+	if len(problems) > 0 {
+		report.Flagged++
+	}
+	if len(hard) > 0 && row.Approved {
+		record.Set("approved", false)
+		report.Unapproved++
+	}
+	if shadowBanned {
+		record.Set("shadowed", true)
+	}
+
+	isNew := record.IsNew()
+	if err := txApp.Save(record); err != nil {
+		return err
+	}
+
+	if isNew {
+		report.Created++
+	} else {
+		report.Updated++
+	}
+
+	return nil
+}
+
+// formatFromName picks the format from a file name or content type, for when it isn't given
+func formatFromName(name string) string {
+	switch {
+	case strings.Contains(name, "csv"):
+		return formatCSV
+	case strings.Contains(name, "ndjson"), strings.Contains(name, "jsonl"), strings.Contains(name, "json"):
+		return formatNDJSON
+	}
+
+	return ""
+}
+
+func (h *Handlers) exportLeaderboard(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = formatNDJSON
+	}
+	opts := exportOptions{Status: query.Get("status"), Since: query.Get("since"), Until: query.Get("until")}
+
+	// Check the options before anything is written, after that errors can't change the status
+	if _, _, err := exportFilter(opts); err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	contentType := "application/x-ndjson"
+	if format == formatCSV {
+		contentType = "text/csv; charset=utf-8"
+	} else if format != formatNDJSON {
+		return e.BadRequestError("Unknown format, use csv or ndjson", nil)
+	}
+
+	e.Response.Header().Set("Content-Type", contentType)
+	e.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="leaderboard-%s.%s"`, time.Now().Format("20060102"), format))
+	e.Response.WriteHeader(http.StatusOK)
+
+	if _, err := exportEntries(h.app, e.Response, format, opts); err != nil {
+		log.Printf("leaderboard export failed: %v", err)
+	}
+
+	return nil
+}
+
+// importLeaderboard takes the file as the raw request body, the format from ?format= or the content type
+func (h *Handlers) importLeaderboard(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = formatFromName(e.Request.Header.Get("Content-Type"))
+	}
+
+	conflict := query.Get("conflict")
+	if conflict == "" {
+		conflict = conflictSkip
+	}
+
+	dryRun, _ := strconv.ParseBool(query.Get("dryRun"))
+
+	report, err := h.importEntries(e.Request.Body, format, conflict, dryRun)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	status := http.StatusOK
+	if len(report.Errors) > 0 && !dryRun {
+		status = http.StatusUnprocessableEntity
+	}
+
+	return e.JSON(status, report)
+}
+
+// openImportFile opens the file to import, "-" being stdin
+func openImportFile(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	return os.Open(filepath.Clean(name))
+}
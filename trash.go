@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Deleted entries stay in the trash for TRASH_RETENTION_DAYS before the purge job removes them for good
+const defaultTrashRetentionDays = 30
+
+var errEntryDeleted = errors.New("entry is in the trash")
+
+func trashRetentionDays() int {
+	if days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && days > 0 {
+		return days
+	}
+
+	return defaultTrashRetentionDays
+}
+
+func isDeleted(record *core.Record) bool {
+	return !record.GetDateTime("deleted_at").IsZero()
+}
+
+// findActiveEntry loads a leaderboard entry for moderation, treating trashed entries as gone
+func (h *Handlers) findActiveEntry(id string) (*core.Record, error) {
+	record, err := h.app.FindRecordById("leaderboard", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if isDeleted(record) {
+		return nil, errEntryDeleted
+	}
+
+	return record, nil
+}
+
+// purgeTrash permanently deletes entries that have been in the trash longer than the retention period
+func (h *Handlers) purgeTrash() error {
+	cutoff := types.NowDateTime().Add(-time.Duration(trashRetentionDays()) * 24 * time.Hour)
+
+	records, err := h.app.FindRecordsByFilter(
+		"leaderboard",
+		"deleted_at != '' && deleted_at < {:cutoff}",
+		"deleted_at",
+		0,
+		0,
+		dbx.Params{"cutoff": cutoff.String()},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if err := h.app.Delete(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type TrashEntry struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Identifier      string `json:"identifier"`
+	Score           int    `json:"score"`
+	LevelsCompleted int    `json:"levelsCompleted"`
+	Approved        bool   `json:"approved"`
+	DeletedAt       string `json:"deletedAt"`
+	PurgeAt         string `json:"purgeAt"`
+}
+
+func (h *Handlers) getTrash(e *core.RequestEvent) error {
+	records, err := h.app.FindRecordsByFilter(
+		"leaderboard",
+		"deleted_at != ''",
+		"-deleted_at",
+		500,
+		0,
+	)
+	if err != nil {
+		return e.InternalServerError("Failed to fetch trash", err)
+	}
+
+	retention := time.Duration(trashRetentionDays()) * 24 * time.Hour
+
+	entries := make([]TrashEntry, len(records))
+	for i, record := range records {
+		deletedAt := record.GetDateTime("deleted_at").Time()
+		entries[i] = TrashEntry{
+			ID:              record.Id,
+			Name:            record.GetString("name"),
+			Identifier:      record.GetString("identifier"),
+			Score:           record.GetInt("score"),
+			LevelsCompleted: record.GetInt("levels_completed"),
+			Approved:        record.GetBool("approved"),
+			DeletedAt:       deletedAt.Format(time.RFC3339),
+			PurgeAt:         deletedAt.Add(retention).Format(time.RFC3339),
+		}
+	}
+
+	return e.JSON(http.StatusOK, entries)
+}
+
+func (h *Handlers) restoreFromTrash(e *core.RequestEvent) error {
+	record, err := h.app.FindRecordById("leaderboard", e.Request.PathValue("id"))
+	if err != nil || !isDeleted(record) {
+		return e.NotFoundError("Entry not in trash", err)
+	}
+
+	err = h.app.RunInTransaction(func(txApp core.App) error {
+		return restoreEntry(txApp, record, e.Auth.Email(), e.Request.FormValue("reason"))
+	})
+	if err != nil {
+		return e.InternalServerError("Failed to restore entry", err)
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
+
+// signedRestoreScore is the undo link shown after deleting. The form on that page POSTs straight
+// here, a GET (say the link was copied elsewhere) asks to confirm first.
+func (h *Handlers) signedRestoreScore(e *core.RequestEvent) error {
+	id := e.Request.PathValue("id")
+	signature := e.Request.PathValue("signature")
+	recipient := e.Request.URL.Query().Get("moderator")
+
+	if !h.verifySignature("restore", id, recipient, signature) {
+		metrics.signatureFailures.inc("restore")
+		return e.BadRequestError("Invalid signature", nil)
+	}
+
+	record, err := h.app.FindRecordById("leaderboard", id)
+	if err != nil || !isDeleted(record) {
+		return e.NotFoundError("Entry not in trash", err)
+	}
+
+	data := restorePageData{Name: record.GetString("name"), Score: record.GetInt("score")}
+
+	if e.Request.Method == "POST" {
+		if !h.verifyCSRF(e, "restore:"+id) {
+			return csrfError(e)
+		}
+
+		err = h.app.RunInTransaction(func(txApp core.App) error {
+			return restoreEntry(txApp, record, moderatorIdentity(e, recipient), "undo")
+		})
+		if err != nil {
+			return e.InternalServerError("Failed to restore score", err)
+		}
+
+		data.Restored = true
+	} else {
+		data.CSRF = h.csrfToken(e, "restore:"+id)
+	}
+
+	return renderPage(e, http.StatusOK, "restore", data)
+}
+
+// trashPage lists deleted entries until they're purged and restores them
+func (h *Handlers) trashPage(e *core.RequestEvent) error {
+	return renderPage(e, http.StatusOK, "trash", nil)
+}
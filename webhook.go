@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Payload formats a webhook can send, set with MODERATION_WEBHOOK_FORMAT
+const (
+	webhookFormatJSON    = "json"
+	webhookFormatSlack   = "slack"
+	webhookFormatDiscord = "discord"
+)
+
+var errWebhookSecretMissing = errors.New("webhook secret is not set, refusing to send an unsigned delivery")
+
+// webhookModerator is who the moderation log credits for actions taken from webhook links
+const webhookModerator = "webhook"
+
+// WebhookNotifier POSTs moderation notices to a URL. Every delivery carries
+// X-Webhook-Timestamp and X-Webhook-Signature headers, the signature being
+// "sha256=" + hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret.
+type WebhookNotifier struct {
+	app    core.App
+	URL    string
+	Secret string
+	Format string
+	Client *http.Client
+}
+
+type webhookPayload struct {
+	Event      string           `json:"event"`
+	Entry      ModerationNotice `json:"entry"`
+	ApproveURL string           `json:"approveUrl"`
+	DeleteURL  string           `json:"deleteUrl"`
+}
+
+func NewWebhookNotifier(app core.App, url, secret, format string) *WebhookNotifier {
+	return &WebhookNotifier{
+		app:    app,
+		URL:    url,
+		Secret: secret,
+		Format: format,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewWebhookNotifierFromEnv returns nil unless MODERATION_WEBHOOK_URL is set. The webhook stays
+// off without MODERATION_WEBHOOK_SECRET, receivers have no other way to tell our deliveries apart.
+func NewWebhookNotifierFromEnv(app core.App) *WebhookNotifier {
+	url := os.Getenv("MODERATION_WEBHOOK_URL")
+	if url == "" {
+		return nil
+	}
+
+	secret := os.Getenv("MODERATION_WEBHOOK_SECRET")
+	if secret == "" {
+		log.Printf("MODERATION_WEBHOOK_URL is set but MODERATION_WEBHOOK_SECRET isn't, not sending webhooks")
+		return nil
+	}
+
+	format := os.Getenv("MODERATION_WEBHOOK_FORMAT")
+	if format == "" {
+		format = webhookFormatJSON
+	}
+
+	return NewWebhookNotifier(app, url, secret, format)
+}
+
+func (w *WebhookNotifier) Channel() string {
+	return "webhook"
+}
+
+// Recipients is the one endpoint, named after who its links are signed for
+func (w *WebhookNotifier) Recipients() ([]string, error) {
+	return []string{webhookModerator}, nil
+}
+
+func (w *WebhookNotifier) Notify(notice ModerationNotice, recipient string, signer SignatureGenerator) error {
+	approveURL := signedModerationURL(w.app, "approve", notice.ID, webhookModerator, signer)
+	deleteURL := signedModerationURL(w.app, "delete", notice.ID, webhookModerator, signer)
+
+	body, err := w.payload(notice, approveURL, deleteURL)
+	if err != nil {
+		return err
+	}
+
+	return w.deliver(body)
+}
+
+func (w *WebhookNotifier) payload(notice ModerationNotice, approveURL, deleteURL string) ([]byte, error) {
+	action := "updated"
+	if notice.IsNew {
+		action = "submitted"
+	}
+
+	summary := fmt.Sprintf("Leaderboard score %s: %s - %d points, %d/20 levels", action, notice.Name, notice.Score, notice.LevelsCompleted)
+	if len(notice.Flags) > 0 {
+		summary += fmt.Sprintf(" (flags: %s)", strings.Join(notice.Flags, ", "))
+	}
+
+	switch w.Format {
+	case webhookFormatSlack:
+		return json.Marshal(map[string]string{
+			"text": fmt.Sprintf("%s\n<%s|✅ Approve> · <%s|🗑️ Delete>", summary, approveURL, deleteURL),
+		})
+	case webhookFormatDiscord:
+		return json.Marshal(map[string]string{
+			"content": fmt.Sprintf("%s\n[✅ Approve](%s) · [🗑️ Delete](%s)", summary, approveURL, deleteURL),
+		})
+	default:
+		return json.Marshal(webhookPayload{
+			Event:      "score." + action,
+			Entry:      notice,
+			ApproveURL: approveURL,
+			DeleteURL:  deleteURL,
+		})
+	}
+}
+
+func (w *WebhookNotifier) deliver(body []byte) error {
+	if w.Secret == "" {
+		return errWebhookSecretMissing
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(w.Secret, timestamp, body))
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook delivery failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// signWebhook is what receivers recompute to verify a delivery came from us
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type webhookDelivery struct {
+	body      []byte
+	timestamp string
+	signature string
+}
+
+// webhookStandIn records every delivery and answers with the given statuses in turn, 200 once they run out
+func webhookStandIn(t *testing.T, statuses ...int) (*httptest.Server, func() []webhookDelivery) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var deliveries []webhookDelivery
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		deliveries = append(deliveries, webhookDelivery{
+			body:      body,
+			timestamp: r.Header.Get("X-Webhook-Timestamp"),
+			signature: r.Header.Get("X-Webhook-Signature"),
+		})
+		status := http.StatusOK
+		if len(deliveries) <= len(statuses) {
+			status = statuses[len(deliveries)-1]
+		}
+		mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []webhookDelivery {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]webhookDelivery(nil), deliveries...)
+	}
+}
+
+// outboxRecord is an in-memory outbox row, deliverOutboxEntry only reads and sets fields on it
+func outboxRecord(channel, recipient string, notice ModerationNotice) *core.Record {
+	collection := core.NewBaseCollection("outbox")
+	collection.Fields.Add(
+		&core.TextField{Name: "channel"},
+		&core.TextField{Name: "recipient"},
+		&core.JSONField{Name: "payload"},
+		&core.SelectField{Name: "status", Values: []string{outboxPending, outboxSent, outboxFailed}},
+		&core.NumberField{Name: "attempts"},
+		&core.DateField{Name: "next_attempt"},
+		&core.TextField{Name: "last_error"},
+		&core.DateField{Name: "sent_at"},
+	)
+
+	record := core.NewRecord(collection)
+	record.Set("channel", channel)
+	record.Set("recipient", recipient)
+	record.Set("payload", notice)
+	record.Set("status", outboxPending)
+
+	return record
+}
+
+func TestWebhookDeliveryIsSignedAndRetried(t *testing.T) {
+	t.Setenv("ADMIN_SIGNING_KEY", "test-signing-key")
+
+	server, deliveries := webhookStandIn(t, http.StatusServiceUnavailable)
+
+	app := core.NewBaseApp(core.BaseAppConfig{DataDir: t.TempDir()})
+	webhook := NewWebhookNotifier(app, server.URL, "test-secret", webhookFormatJSON)
+	h := &Handlers{notifiers: []Notifier{webhook}}
+
+	notice := ModerationNotice{ID: "abc123def456ghi", Name: "Sneaky Otter", Score: 4200, LevelsCompleted: 7, IsNew: true}
+	record := outboxRecord(webhook.Channel(), webhookModerator, notice)
+
+	// The stand-in fails the first delivery, so it stays queued for another attempt
+	h.deliverOutboxEntry(record)
+
+	if status := record.GetString("status"); status != outboxPending {
+		t.Fatalf("status after a 503 = %q, want %q", status, outboxPending)
+	}
+	if attempts := record.GetInt("attempts"); attempts != 1 {
+		t.Fatalf("attempts after a 503 = %d, want 1", attempts)
+	}
+	if lastError := record.GetString("last_error"); !strings.Contains(lastError, "503") {
+		t.Fatalf("last_error = %q, want it to mention the 503", lastError)
+	}
+
+	h.deliverOutboxEntry(record)
+
+	if status := record.GetString("status"); status != outboxSent {
+		t.Fatalf("status after the retry = %q, want %q", status, outboxSent)
+	}
+	if attempts := record.GetInt("attempts"); attempts != 2 {
+		t.Fatalf("attempts after the retry = %d, want 2", attempts)
+	}
+
+	got := deliveries()
+	if len(got) != 2 {
+		t.Fatalf("stand-in got %d deliveries, want 2", len(got))
+	}
+
+	for i, delivery := range got {
+		if delivery.timestamp == "" {
+			t.Fatalf("delivery %d has no X-Webhook-Timestamp", i)
+		}
+
+		want := "sha256=" + signWebhook("test-secret", delivery.timestamp, delivery.body)
+		if delivery.signature != want {
+			t.Fatalf("delivery %d signature = %q, want %q", i, delivery.signature, want)
+		}
+
+		var payload webhookPayload
+		if err := json.Unmarshal(delivery.body, &payload); err != nil {
+			t.Fatalf("delivery %d isn't JSON: %v", i, err)
+		}
+		if payload.Event != "score.submitted" {
+			t.Fatalf("delivery %d event = %q, want score.submitted", i, payload.Event)
+		}
+		if payload.Entry.ID != notice.ID || payload.Entry.Name != notice.Name || payload.Entry.Score != notice.Score {
+			t.Fatalf("delivery %d entry = %+v, want %+v", i, payload.Entry, notice)
+		}
+		if !strings.Contains(payload.ApproveURL, "/admin/approve/"+notice.ID+"/") ||
+			!strings.Contains(payload.DeleteURL, "/admin/delete/"+notice.ID+"/") {
+			t.Fatalf("delivery %d links = %q, %q", i, payload.ApproveURL, payload.DeleteURL)
+		}
+	}
+}
+
+func TestWebhookRefusesToSendUnsigned(t *testing.T) {
+	server, deliveries := webhookStandIn(t)
+
+	app := core.NewBaseApp(core.BaseAppConfig{DataDir: t.TempDir()})
+	webhook := NewWebhookNotifier(app, server.URL, "", webhookFormatJSON)
+
+	if err := webhook.Notify(ModerationNotice{ID: "abc123def456ghi"}, webhookModerator, &Handlers{}); err == nil {
+		t.Fatal("Notify without a secret succeeded, want an error")
+	}
+	if got := deliveries(); len(got) != 0 {
+		t.Fatalf("stand-in got %d deliveries without a secret, want none", len(got))
+	}
+
+	t.Setenv("MODERATION_WEBHOOK_URL", server.URL)
+	t.Setenv("MODERATION_WEBHOOK_SECRET", "")
+	if notifier := NewWebhookNotifierFromEnv(app); notifier != nil {
+		t.Fatal("NewWebhookNotifierFromEnv registered a webhook without a secret")
+	}
+}
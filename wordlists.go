@@ -0,0 +1,215 @@
+package main
+
+import (
+	"cookie-banner-clicker/filter"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Built-in word lists, one <locale>.txt per language. A file with the same name in
+// WORD_LISTS_DIR replaces the built-in one, and lists in the word_lists collection are
+// added on top. Edits to either are picked up without a restart.
+//
+//go:embed wordlists
+var wordListsFS embed.FS
+
+const defaultLocale = "en"
+
+// defaultFilterLocale is the language whose word list applies to every name, FILTER_DEFAULT_LOCALE
+func defaultFilterLocale() string {
+	if locale := os.Getenv("FILTER_DEFAULT_LOCALE"); locale != "" {
+		return strings.ToLower(locale)
+	}
+
+	return defaultLocale
+}
+
+// requestLocales lists the languages from the Accept-Language header, most preferred first
+func requestLocales(e *core.RequestEvent) []string {
+	var locales []string
+	for _, part := range strings.Split(e.Request.Header.Get("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && tag != "*" {
+			locales = append(locales, tag)
+		}
+	}
+
+	return locales
+}
+
+// joinLocales is how the locales a name was accepted under are stored on the entry, so re-checks
+// use the same lists. It keeps as many as fit in the field.
+func joinLocales(locales []string) string {
+	joined := ""
+	for _, locale := range locales {
+		if len(joined)+len(locale)+1 > maxStoredLocalesLength {
+			break
+		}
+		if joined != "" {
+			joined += ","
+		}
+		joined += locale
+	}
+
+	return joined
+}
+
+func splitLocales(joined string) []string {
+	if joined == "" {
+		return nil
+	}
+
+	return strings.Split(joined, ",")
+}
+
+const maxStoredLocalesLength = 255
+
+// RegisterWordLists loads the name filter's word lists and reloads them whenever an admin
+// edits the word_lists collection or a file in WORD_LISTS_DIR changes
+func (h *Handlers) RegisterWordLists() {
+	h.reloadWordLists()
+
+	reload := func(e *core.RecordEvent) error {
+		h.reloadWordLists()
+		return e.Next()
+	}
+	h.app.OnRecordAfterCreateSuccess("word_lists").BindFunc(reload)
+	h.app.OnRecordAfterUpdateSuccess("word_lists").BindFunc(reload)
+	h.app.OnRecordAfterDeleteSuccess("word_lists").BindFunc(reload)
+
+	h.app.Cron().MustAdd("wordListFiles", "* * * * *", func() {
+		h.wordListsMu.Lock()
+		changed := wordListFilesVersion() != h.wordListsVersion
+		h.wordListsMu.Unlock()
+
+		if changed {
+			h.reloadWordLists()
+		}
+	})
+}
+
+// reloadWordLists rebuilds the filter from every source. A source that fails to load is logged
+// and skipped rather than leaving names unfiltered.
+func (h *Handlers) reloadWordLists() {
+	h.wordListsMu.Lock()
+	defer h.wordListsMu.Unlock()
+
+	h.wordListsVersion = wordListFilesVersion()
+
+	lists, err := wordListFiles()
+	if err != nil {
+		log.Printf("failed to load word list files: %v", err)
+	}
+
+	records, err := h.app.FindRecordsByFilter("word_lists", "enabled = true", "locale", 0, 0)
+	if err != nil {
+		log.Printf("failed to load word lists: %v", err)
+	}
+
+	for _, record := range records {
+		list := filter.WordList{Locale: record.GetString("locale")}
+		if err := record.UnmarshalJSONField("words", &list.Words); err != nil {
+			log.Printf("word list %s has invalid words: %v", record.Id, err)
+		}
+		if err := record.UnmarshalJSONField("allow", &list.Allow); err != nil {
+			log.Printf("word list %s has invalid allow-list: %v", record.Id, err)
+		}
+		lists = append(lists, list)
+	}
+
+	h.nameFilter.Load(lists)
+}
+
+// wordListFiles reads the built-in lists, letting WORD_LISTS_DIR replace or add to them. A file
+// that can't be read or parsed is logged and skipped, falling back to the built-in list it was
+// meant to replace, so one bad file doesn't take the other lists down with it.
+func wordListFiles() ([]filter.WordList, error) {
+	// Every source for a file name, the one to try first last
+	files := map[string][]fs.FS{}
+
+	builtin, err := fs.Sub(wordListsFS, "wordlists")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(builtin, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		files[entry.Name()] = append(files[entry.Name()], builtin)
+	}
+
+	// Without the directory the built-in lists still apply, so carry on and report it at the end
+	var dirErr error
+	if dir := os.Getenv("WORD_LISTS_DIR"); dir != "" {
+		entries, err := os.ReadDir(dir)
+		dirErr = err
+		for _, entry := range entries {
+			files[entry.Name()] = append(files[entry.Name()], os.DirFS(dir))
+		}
+	}
+
+	var lists []filter.WordList
+	for name, sources := range files {
+		if path.Ext(name) != ".txt" {
+			continue
+		}
+
+		for i := len(sources) - 1; i >= 0; i-- {
+			list, err := readWordList(sources[i], name)
+			if err != nil {
+				log.Printf("skipping word list %s: %v", name, err)
+				continue
+			}
+
+			lists = append(lists, list)
+			break
+		}
+	}
+
+	return lists, dirErr
+}
+
+func readWordList(fsys fs.FS, name string) (filter.WordList, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return filter.WordList{}, err
+	}
+	defer file.Close()
+
+	return filter.Parse(strings.TrimSuffix(name, ".txt"), file)
+}
+
+// wordListFilesVersion changes whenever a file in WORD_LISTS_DIR is added, removed or modified
+func wordListFilesVersion() string {
+	dir := os.Getenv("WORD_LISTS_DIR")
+	if dir == "" {
+		return ""
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err.Error()
+	}
+
+	var parts []string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", entry.Name(), info.Size(), info.ModTime().UnixNano()))
+	}
+	sort.Strings(parts)
+
+	return strings.Join(parts, ",")
+}